@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"go.opentelemetry.io/contrib/propagators/aws/xray"
@@ -20,6 +21,57 @@ import (
 	"google.golang.org/grpc"
 )
 
+// semconvMode returns the HTTP semantic conventions of the spans selected by OTEL_SEMCONV_STABILITY_OPT_IN: "stable"
+// for http, "dup" for http/dup and "old" otherwise. It follows semconvMode of the go-sample-app collection package,
+// which this module cannot import since the sample apps are built as separate modules.
+func semconvMode() string {
+	mode := "old"
+	for _, value := range strings.Split(os.Getenv("OTEL_SEMCONV_STABILITY_OPT_IN"), ",") {
+		switch strings.TrimSpace(value) {
+		case "http/dup":
+			return "dup"
+		case "http":
+			mode = "stable"
+		}
+	}
+	return mode
+}
+
+// httpAttributes returns the attributes of a request to rawURL in the order the spans always carried them, with the
+// stable HTTP attributes added depending on mode. http.method and http.url are kept in every mode because the X-Ray
+// sampling rules checked by the integration tests match on them, so the mode never changes the sampling decisions.
+// The stable mode only replaces http.target, which no rule uses, with url.path.
+func httpAttributes(mode, method, rawURL, route, user, required string) []attribute.KeyValue {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		log.Println(err)
+		u = &url.URL{Path: route}
+	}
+
+	attributes := []attribute.KeyValue{
+		attribute.String("http.method", method),
+		attribute.String("http.url", rawURL),
+		attribute.String("user", user),
+		attribute.String("http.route", route),
+		attribute.String("required", required),
+	}
+	if mode != "stable" {
+		attributes = append(attributes, attribute.String("http.target", u.RequestURI()))
+	}
+	if mode != "old" {
+		attributes = append(attributes,
+			attribute.String("http.request.method", method),
+			attribute.String("url.full", rawURL),
+			attribute.String("url.path", u.Path),
+			attribute.String("server.address", u.Hostname()),
+		)
+		if port, err := strconv.Atoi(u.Port()); err == nil {
+			attributes = append(attributes, attribute.Int("server.port", port))
+		}
+	}
+	return attributes
+}
+
 func getSampledSpanCount(name string, totalSpans string, attributes []attribute.KeyValue) (int, error) {
 	tracer := otel.Tracer(name)
 
@@ -58,14 +110,7 @@ func webServer() {
 		serviceName := r.Header.Get("Service_name")
 		totalSpans := r.Header.Get("Totalspans")
 
-		var attributes = []attribute.KeyValue{
-			attribute.KeyValue{"http.method", attribute.StringValue(r.Method)},
-			attribute.KeyValue{"http.url", attribute.StringValue("http://localhost:8080/getSampled")},
-			attribute.KeyValue{"user", attribute.StringValue(userAttribute)},
-			attribute.KeyValue{"http.route", attribute.StringValue("/getSampled")},
-			attribute.KeyValue{"required", attribute.StringValue(required)},
-			attribute.KeyValue{"http.target", attribute.StringValue("/getSampled")},
-		}
+		var attributes = httpAttributes(semconvMode(), r.Method, "http://localhost:8080/getSampled", "/getSampled", userAttribute, required)
 
 		totalSampled, err := getSampledSpanCount(serviceName, totalSpans, attributes)
 		if err != nil {
@@ -84,14 +129,7 @@ func webServer() {
 		serviceName := r.Header.Get("Service_name")
 		totalSpans := r.Header.Get("Totalspans")
 
-		var attributes = []attribute.KeyValue{
-			attribute.KeyValue{"http.method", attribute.StringValue("GET")},
-			attribute.KeyValue{"http.url", attribute.StringValue("http://localhost:8080/importantEndpoint")},
-			attribute.KeyValue{"user", attribute.StringValue(userAttribute)},
-			attribute.KeyValue{"http.route", attribute.StringValue("/importantEndpoint")},
-			attribute.KeyValue{"required", attribute.StringValue(required)},
-			attribute.KeyValue{"http.target", attribute.StringValue("/importantEndpoint")},
-		}
+		var attributes = httpAttributes(semconvMode(), "GET", "http://localhost:8080/importantEndpoint", "/importantEndpoint", userAttribute, required)
 
 		totalSampled, err := getSampledSpanCount(serviceName, totalSpans, attributes)
 		if err != nil {
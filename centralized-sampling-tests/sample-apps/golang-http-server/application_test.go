@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestHTTPAttributes(t *testing.T) {
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		{"old", []string{"http.method", "http.url", "user", "http.route", "required", "http.target"}},
+		{"stable", []string{"http.method", "http.url", "user", "http.route", "required",
+			"http.request.method", "url.full", "url.path", "server.address", "server.port"}},
+		{"dup", []string{"http.method", "http.url", "user", "http.route", "required", "http.target",
+			"http.request.method", "url.full", "url.path", "server.address", "server.port"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			attrs := httpAttributes(tt.mode, "GET", "http://localhost:8080/getSampled", "/getSampled", "alice", "true")
+			if len(attrs) != len(tt.want) {
+				t.Fatalf("httpAttributes(%s) = %v, want the keys %v", tt.mode, attrs, tt.want)
+			}
+			values := map[attribute.Key]attribute.Value{}
+			for i, kv := range attrs {
+				if string(kv.Key) != tt.want[i] {
+					t.Errorf("attribute %d = %s, want %s", i, kv.Key, tt.want[i])
+				}
+				values[kv.Key] = kv.Value
+			}
+			if got := values["http.url"].AsString(); got != "http://localhost:8080/getSampled" {
+				t.Errorf("http.url = %q", got)
+			}
+			if tt.mode != "old" {
+				if got := values["url.full"].AsString(); got != "http://localhost:8080/getSampled" {
+					t.Errorf("url.full = %q", got)
+				}
+				if got := values["server.port"].AsInt64(); got != 8080 {
+					t.Errorf("server.port = %d, want 8080", got)
+				}
+			}
+		})
+	}
+}
+
+func TestSemconvMode(t *testing.T) {
+	for value, want := range map[string]string{"": "old", "http": "stable", "http/dup": "dup", "db, http": "stable", "http,http/dup": "dup"} {
+		t.Setenv("OTEL_SEMCONV_STABILITY_OPT_IN", value)
+		if got := semconvMode(); got != want {
+			t.Errorf("semconvMode() with %q = %s, want %s", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package collection
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Contains the selection of the span attributes indexed by X-Ray as annotations.
+
+// headerAnnotationsKey is the context key of the annotations read from the headers of the incoming request.
+type headerAnnotationsKey struct{}
+
+// xrayAnnotator sets the configured annotation attributes on spans and lists every annotation key in the
+// aws.xray.annotations attribute, which the X-Ray exporters turn into annotations. The other attributes become
+// metadata.
+type xrayAnnotator struct {
+	cfg     XrayAnnotationsConfig
+	static  []attribute.KeyValue
+	headers []attribute.KeyValue
+}
+
+// newXrayAnnotator returns the annotator of cfg, or nil when no annotation is configured.
+func newXrayAnnotator(cfg XrayAnnotationsConfig) *xrayAnnotator {
+	if len(cfg.Keys)+len(cfg.Static)+len(cfg.FromHeaders)+len(cfg.FromBaggage) == 0 {
+		return nil
+	}
+	return &xrayAnnotator{
+		cfg:     cfg,
+		static:  keyValueAttributes(cfg.Static),
+		headers: keyValueAttributes(cfg.FromHeaders),
+	}
+}
+
+// attributes returns the annotations of a span started in ctx: the static ones, the ones read from the request
+// headers and the allowed baggage entries, followed by the aws.xray.annotations list.
+func (x *xrayAnnotator) attributes(ctx context.Context) []attribute.KeyValue {
+	attrs := append([]attribute.KeyValue{}, x.static...)
+	if fromHeaders, ok := ctx.Value(headerAnnotationsKey{}).([]attribute.KeyValue); ok {
+		attrs = append(attrs, fromHeaders...)
+	}
+	attrs = append(attrs, baggageAttributes(ctx, x.cfg.FromBaggage)...)
+
+	seen := map[string]bool{}
+	var keys []string
+	for _, key := range x.cfg.Keys {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	for _, kv := range attrs {
+		if key := string(kv.Key); !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return append(attrs, xrayAnnotationsKey.StringSlice(keys))
+}
+
+func (x *xrayAnnotator) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	s.SetAttributes(x.attributes(parent)...)
+}
+
+func (x *xrayAnnotator) OnEnd(sdktrace.ReadOnlySpan) {}
+
+func (x *xrayAnnotator) Shutdown(context.Context) error { return nil }
+
+func (x *xrayAnnotator) ForceFlush(context.Context) error { return nil }
+
+// xrayAnnotationsMiddleware reads the configured headers of the request into its context, so that every span of
+// the request is annotated with them, and annotates the server span which was started before.
+func (a *App) xrayAnnotationsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var fromHeaders []attribute.KeyValue
+		for _, h := range a.annotator.headers {
+			if value := r.Header.Get(h.Value.AsString()); value != "" {
+				fromHeaders = append(fromHeaders, attribute.String(string(h.Key), value))
+			}
+		}
+		ctx := r.Context()
+		if len(fromHeaders) > 0 {
+			ctx = context.WithValue(ctx, headerAnnotationsKey{}, fromHeaders)
+			r = r.WithContext(ctx)
+		}
+		trace.SpanFromContext(ctx).SetAttributes(a.annotator.attributes(ctx)...)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,68 @@
+package collection
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestXrayAnnotatorAttributes(t *testing.T) {
+	if newXrayAnnotator(XrayAnnotationsConfig{}) != nil {
+		t.Error("an annotator was created without any annotation")
+	}
+
+	x := newXrayAnnotator(XrayAnnotationsConfig{
+		Keys:        []string{"signal", "team"},
+		Static:      []string{"team=payments"},
+		FromHeaders: []string{"tenant=X-Tenant-Id"},
+		FromBaggage: []string{"user.tier", "missing"},
+	})
+	member, err := baggage.NewMember("user.tier", "gold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bag, err := baggage.New(member)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := baggage.ContextWithBaggage(context.Background(), bag)
+	ctx = context.WithValue(ctx, headerAnnotationsKey{}, []attribute.KeyValue{attribute.String("tenant", "acme")})
+
+	want := []attribute.KeyValue{
+		attribute.String("team", "payments"),
+		attribute.String("tenant", "acme"),
+		attribute.String("user.tier", "gold"),
+		// The configured keys come first, each key is listed once
+		xrayAnnotationsKey.StringSlice([]string{"signal", "team", "tenant", "user.tier"}),
+	}
+	if got := x.attributes(ctx); !reflect.DeepEqual(got, want) {
+		t.Errorf("attributes() = %v, want %v", got, want)
+	}
+}
+
+func TestXrayAnnotationsMiddleware(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.XrayAnnotations.FromHeaders = []string{"tenant=X-Tenant-Id"}
+	ta := newTestApp(t, cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Tenant-Id", "acme")
+	ta.Handler().ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := ta.spans.GetSpans()
+	if len(spans) != 1 || spans[0].SpanKind != trace.SpanKindServer {
+		t.Fatalf("recorded %d spans, want the server span", len(spans))
+	}
+	if got := spanAttr(spans[0], "tenant").AsString(); got != "acme" {
+		t.Errorf("tenant = %q, want acme", got)
+	}
+	if got := spanAttr(spans[0], xrayAnnotationsKey).AsStringSlice(); !reflect.DeepEqual(got, []string{"tenant"}) {
+		t.Errorf("%s = %v, want [tenant]", xrayAnnotationsKey, got)
+	}
+}
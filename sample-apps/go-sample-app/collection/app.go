@@ -0,0 +1,433 @@
+package collection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+const instrumentationName = "github.com/aws-otel-commnunity/sample-apps/go-sample-app/collection"
+
+// App is a self-contained instance of the sample app. It owns its configuration, telemetry providers,
+// metric collectors and router, so several instances can be embedded and run side by side in one process.
+type App struct {
+	cfg        *Config
+	tp         trace.TracerProvider
+	mp         metric.MeterProvider
+	propagator propagation.TextMapPropagator
+	detectors  []resource.Detector
+	client     *http.Client
+	router     *mux.Router
+	rand       *rand.Rand
+	ids        sdktrace.IDGenerator // ID generator of the tracer provider the App starts
+	tracer     trace.Tracer
+	s3         *s3Client
+	rmc        *RandomMetricCollector
+	rqmc       *RequestBasedMetricCollector
+	smc        *SyntheticMetricCollector
+	db         *database
+	messaging  *messaging
+	jobs       *jobScheduler
+
+	// instanceId identifies this instance, from the configuration or the INSTANCE_ID environment variable. It is the
+	// service.instance.id resource attribute and, unless MetricNameSuffix is set, a metric attribute.
+	instanceId   string
+	metricLabels []attribute.KeyValue // instance ID and configured common attributes of the metrics
+	// testingId is appended to every metric name when MetricNameSuffix is set and there is an instance ID.
+	testingId   string
+	traceLabels []attribute.KeyValue
+
+	// grpcConns holds the client connections to the peer sample apps invoked over gRPC, guarded by grpcMu.
+	grpcMu    sync.Mutex
+	grpcConns map[string]*grpc.ClientConn
+
+	// Telemetry state used by the health and introspection endpoints.
+	startTime     time.Time
+	resource      *resource.Resource
+	traceStatus   *exportStatus
+	traceProbe    *probeTarget // destination of the trace exporter, when the App started it
+	metricProbe   *probeTarget // destination of the metric exporter, when the App started it
+	metricStatus  *exportStatus
+	logStatus     *exportStatus
+	logs          *logEmitter
+	annotator     *xrayAnnotator
+	semconv       string // HTTP semantic conventions mode of the spans, metrics and logs
+	debugReader   sdkmetric.Reader
+	spanCounter   *countingSpanProcessor
+	shutdownFuncs []func(context.Context) error
+	cancel        context.CancelFunc
+}
+
+// Option configures an App.
+type Option func(*App)
+
+// WithConfig sets the configuration of the App. GetConfiguration is used when it is not provided.
+func WithConfig(cfg *Config) Option {
+	return func(a *App) {
+		a.cfg = cfg
+	}
+}
+
+// WithTracerProvider sets the tracer provider used for all spans of the App. When neither a tracer provider nor a
+// meter provider is provided, the App starts its own OTLP providers. The spans of an injected provider are exported
+// as recorded: they are not converted to SemconvStability. The X-Ray annotation and Baggage.AllowList span processors
+// are only registered on the provider the App starts, so with an injected provider only the server spans get the
+// annotations and no span gets the baggage attributes.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(a *App) {
+		a.tp = tp
+	}
+}
+
+// WithMeterProvider sets the meter provider used for all metrics of the App. The request based metrics follow
+// SemconvStability, but the metrics of the instrumentations are only converted by the provider the App starts.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(a *App) {
+		a.mp = mp
+	}
+}
+
+// WithPropagator sets the propagator used for incoming and outgoing requests. Defaults to the AWS X-Ray propagator
+// combined with the W3C baggage propagator.
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(a *App) {
+		a.propagator = p
+	}
+}
+
+// WithResourceDetectors adds the resources detected by detectors to the resource of the providers started by the
+// App, e.g. the AWS Lambda resource detector.
+func WithResourceDetectors(detectors ...resource.Detector) Option {
+	return func(a *App) {
+		a.detectors = append(a.detectors, detectors...)
+	}
+}
+
+// WithHTTPClient sets the client used for outgoing calls. The client should be instrumented by the caller.
+func WithHTTPClient(client *http.Client) Option {
+	return func(a *App) {
+		a.client = client
+	}
+}
+
+// WithRouter sets the router the App registers its endpoints on.
+func WithRouter(r *mux.Router) Option {
+	return func(a *App) {
+		a.router = r
+	}
+}
+
+// WithRand sets the random generator feeding every generator of the App: metric values, statement values, injected
+// latencies and faults, and the trace and span IDs of the tracer provider the App starts. It must be safe for
+// concurrent use. Defaults to a generator seeded with the Seed configuration key.
+func WithRand(r *rand.Rand) Option {
+	return func(a *App) {
+		a.rand = r
+	}
+}
+
+// New returns an App configured with the given options. The sample app endpoints are registered on its router. When it
+// fails, the providers, database and messaging it already started are shut down.
+func New(ctx context.Context, opts ...Option) (_ *App, err error) {
+	a := &App{
+		startTime:    time.Now(),
+		traceStatus:  &exportStatus{},
+		metricStatus: &exportStatus{},
+		logStatus:    &exportStatus{},
+		spanCounter:  &countingSpanProcessor{},
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.cfg == nil {
+		a.cfg = GetConfiguration()
+	}
+	if err := a.cfg.Validate(); err != nil {
+		return nil, err
+	}
+	// The IDs are reproducible with a seed or an injected generator. Their source is seeded before any other draw.
+	if a.rand != nil || a.cfg.Seed != 0 {
+		if a.rand == nil {
+			a.rand = newRand(a.cfg.Seed)
+		}
+		a.ids = newIDGenerator(a.rand)
+	} else {
+		a.rand = newRand(0)
+		a.ids = newIDGenerator(nil)
+	}
+	a.instanceId = a.cfg.InstanceId
+	if a.instanceId == "" {
+		a.instanceId = os.Getenv("INSTANCE_ID")
+	}
+	if a.instanceId != "" {
+		if a.cfg.MetricNameSuffix {
+			a.testingId = "_" + a.instanceId
+		} else {
+			a.metricLabels = []attribute.KeyValue{semconv.ServiceInstanceID(a.instanceId)}
+		}
+	}
+	a.metricLabels = append(a.metricLabels, commonAttributes(a.cfg.CommonAttributes.All, a.cfg.CommonAttributes.Metrics)...)
+	a.annotator = newXrayAnnotator(a.cfg.XrayAnnotations)
+	a.semconv = semconvMode(a.cfg.SemconvStability)
+	if a.propagator == nil {
+		a.propagator = propagation.NewCompositeTextMapPropagator(xray.Propagator{}, propagation.Baggage{})
+	}
+	defer func() {
+		if err != nil {
+			a.Shutdown(ctx)
+		}
+	}()
+	if a.tp == nil || a.mp == nil {
+		if err := a.startClient(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if a.cfg.ApplicationSignals.Enabled {
+		sdkTP, ok := a.tp.(*sdktrace.TracerProvider)
+		if !ok {
+			return nil, fmt.Errorf("ApplicationSignals needs an SDK tracer provider, got %T", a.tp)
+		}
+		p, err := newAppSignalsProcessor(a.mp, a.testingId, a.cfg.ServiceName)
+		if err != nil {
+			return nil, err
+		}
+		sdkTP.RegisterSpanProcessor(p)
+	}
+	a.tracer = a.tp.Tracer(instrumentationName)
+	a.traceLabels = []attribute.KeyValue{
+		attribute.String("signal", "trace"),
+		attribute.String("language", serviceName),
+		attribute.String("host", a.cfg.Host),
+		attribute.String("port", a.cfg.Port),
+	}
+	a.traceLabels = append(a.traceLabels, commonAttributes(a.cfg.CommonAttributes.All, a.cfg.CommonAttributes.Traces)...)
+
+	if a.client == nil {
+		var transport http.RoundTripper = http.DefaultTransport
+		if a.cfg.ApplicationSignals.Enabled {
+			transport = appSignalsTransport{base: transport}
+		}
+		a.client = &http.Client{
+			Transport: otelhttp.NewTransport(
+				transport,
+				otelhttp.WithTracerProvider(a.tp),
+				otelhttp.WithMeterProvider(a.mp),
+				otelhttp.WithPropagators(a.propagator),
+			),
+		}
+	}
+	if a.router == nil {
+		a.router = mux.NewRouter()
+	}
+
+	s3, err := NewS3Client()
+	if err != nil {
+		return nil, err
+	}
+	a.s3 = s3
+
+	// (Metric related) Creates the random based and request based metric collectors
+	a.rmc = NewRandomMetricCollector(a.mp, a.rand, a.testingId, a.metricLabels...)
+	a.rqmc = NewRequestBasedMetricCollector(a.mp, a.testingId, a.metricLabels...)
+	if a.cfg.SyntheticMetrics.Count > 0 {
+		if a.smc, err = NewSyntheticMetricCollector(a.mp, a.cfg.SyntheticMetrics, a.rand, a.testingId, a.metricLabels...); err != nil {
+			return nil, err
+		}
+	}
+
+	if a.cfg.Database.Enabled {
+		if a.db, err = openDatabase(ctx, a.tracer, a.mp, a.cfg.Database, a.testingId, a.rqmc.Labels, a.rand); err != nil {
+			return nil, err
+		}
+	}
+
+	if a.messaging, err = newMessaging(a.tracer, a.propagator, a.mp, a.cfg.Messaging, a.testingId, a.rqmc.Labels); err != nil {
+		return nil, err
+	}
+	if a.jobs, err = newJobScheduler(a, a.mp, a.cfg.Jobs, a.testingId, a.metricLabels...); err != nil {
+		return nil, err
+	}
+
+	a.registerRoutes()
+	return a, nil
+}
+
+// registerRoutes registers the sample app endpoints and the custom endpoints of the configuration on the router.
+func (a *App) registerRoutes() {
+	a.router.Use(otelmux.Middleware("Go-Sampleapp-Server",
+		otelmux.WithTracerProvider(a.tp),
+		otelmux.WithPropagators(a.propagator),
+	))
+	if a.annotator != nil {
+		a.router.Use(a.xrayAnnotationsMiddleware)
+	}
+	a.router.Use(a.traceResponseMiddleware)
+	a.router.Use(a.requestMetricsMiddleware)
+	if a.logs != nil {
+		a.router.Use(a.requestLogMiddleware)
+	}
+
+	a.router.HandleFunc("/aws-sdk-call", a.AwsSdkCall)
+	a.router.HandleFunc("/outgoing-http-call", a.OutgoingHttpCall)
+	a.router.HandleFunc("/outgoing-sampleapp", a.OutgoingSampleApp)
+	a.router.HandleFunc("/synthetic-trace", a.SyntheticTrace)
+	if a.db != nil {
+		a.router.HandleFunc("/db-call", a.DbCall)
+	}
+	a.router.HandleFunc("/publish", a.Publish)
+	a.registerEndpoints()
+	a.router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// Start starts the random based and synthetic metric generation, the request based metric callbacks, the message
+// consumers and the scheduled jobs. Background work stops when ctx is cancelled or the App is shut down. An App can
+// only be started once.
+func (a *App) Start(ctx context.Context) error {
+	if a.cancel != nil {
+		return errors.New("the App is already started")
+	}
+	ctx, a.cancel = context.WithCancel(ctx)
+	if err := a.rmc.RegisterMetricsClient(ctx, *a.cfg); err != nil {
+		return err
+	}
+	if a.smc != nil {
+		if err := a.smc.Start(ctx); err != nil {
+			return err
+		}
+	}
+	if err := a.messaging.start(ctx); err != nil {
+		return err
+	}
+	a.jobs.start(ctx)
+	return a.rqmc.StartTotalRequestCallback()
+}
+
+// Handler returns the HTTP handler of the App. Health, readiness and introspection probes are served outside of
+// the router so that they are not traced.
+func (a *App) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", a.router)
+	mux.HandleFunc("/healthz", a.Healthz)
+	mux.HandleFunc("/readyz", a.Readyz)
+	mux.HandleFunc("/debug/telemetry", a.DebugTelemetry)
+	return mux
+}
+
+// Addr returns the address the App is configured to listen on.
+func (a *App) Addr() string {
+	return net.JoinHostPort(a.cfg.Host, a.cfg.Port)
+}
+
+// Config returns the configuration of the App.
+func (a *App) Config() Config {
+	return *a.cfg
+}
+
+// Router returns the router the endpoints are registered on.
+func (a *App) Router() *mux.Router {
+	return a.router
+}
+
+// TracerProvider returns the tracer provider of the App.
+func (a *App) TracerProvider() trace.TracerProvider {
+	return a.tp
+}
+
+// MeterProvider returns the meter provider of the App.
+func (a *App) MeterProvider() metric.MeterProvider {
+	return a.mp
+}
+
+// ForceFlush exports all telemetry recorded so far by providers which support flushing.
+func (a *App) ForceFlush(ctx context.Context) error {
+	type flusher interface {
+		ForceFlush(context.Context) error
+	}
+	if f, ok := a.tp.(flusher); ok {
+		if err := f.ForceFlush(ctx); err != nil {
+			return err
+		}
+	}
+	if f, ok := a.mp.(flusher); ok {
+		if err := f.ForceFlush(ctx); err != nil {
+			return err
+		}
+	}
+	if a.logs != nil {
+		return a.logs.ForceFlush(ctx)
+	}
+	return nil
+}
+
+// Shutdown stops background work and, if the App started its own providers, pushes any last exports to the receiver.
+// Every step runs even when an earlier one fails; the failures are returned together in a *ShutdownError.
+func (a *App) Shutdown(ctx context.Context) error {
+	var errs []error
+	if a.cancel != nil {
+		a.cancel()
+	}
+	a.closeGrpcConns()
+	if a.messaging != nil {
+		if err := a.messaging.close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if a.db != nil {
+		if err := a.db.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	for _, fn := range a.shutdownFuncs {
+		if err := fn(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return &ShutdownError{Errors: errs}
+	}
+	return nil
+}
+
+// ShutdownError lists every error returned while shutting an App down.
+type ShutdownError struct {
+	Errors []error
+}
+
+func (e *ShutdownError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return "shutdown failed:\n  - " + strings.Join(msgs, "\n  - ")
+}
+
+// Unwrap returns the errors of the shutdown, so that errors.Is and errors.As look at each of them from Go 1.20.
+func (e *ShutdownError) Unwrap() []error {
+	return e.Errors
+}
@@ -0,0 +1,109 @@
+package collection
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// testApp is an App exporting its telemetry in memory.
+type testApp struct {
+	*App
+	spans  *tracetest.InMemoryExporter
+	reader sdkmetric.Reader
+}
+
+// testConfig returns the default configuration, without the instance ID of the environment.
+func testConfig(t *testing.T) *Config {
+	t.Helper()
+	t.Setenv("INSTANCE_ID", "")
+	cfg, err := LoadConfiguration("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// newTestApp returns an App configured with cfg whose spans and metrics are kept in memory. The spans recorded by New,
+// such as the ones seeding the database, are dropped. The App is shut down when the test ends.
+func newTestApp(t *testing.T, cfg *Config, opts ...Option) *testApp {
+	t.Helper()
+	ta := &testApp{spans: tracetest.NewInMemoryExporter(), reader: sdkmetric.NewManualReader()}
+	opts = append([]Option{
+		WithConfig(cfg),
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(ta.spans))),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(ta.reader))),
+	}, opts...)
+	app, err := New(context.Background(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { app.Shutdown(context.Background()) })
+	ta.spans.Reset()
+	ta.App = app
+	return ta
+}
+
+// serve sends a request to the handler of the App and returns the recorded response.
+func (ta *testApp) serve(t *testing.T, method, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	ta.Handler().ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+// metrics collects the metrics recorded so far, by name.
+func (ta *testApp) metrics(t *testing.T) map[string]metricdata.Metrics {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := ta.reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	metrics := map[string]metricdata.Metrics{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m
+		}
+	}
+	return metrics
+}
+
+func TestStart(t *testing.T) {
+	ta := newTestApp(t, testConfig(t))
+	if err := ta.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := ta.Start(context.Background()); err == nil {
+		t.Error("a second Start succeeded, want an error")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	ta := newTestApp(t, testConfig(t))
+	errFirst, errSecond := errors.New("first"), errors.New("second")
+	var calls int
+	ta.shutdownFuncs = []func(context.Context) error{
+		func(context.Context) error { calls++; return errFirst },
+		func(context.Context) error { calls++; return nil },
+		func(context.Context) error { calls++; return errSecond },
+	}
+
+	err := ta.Shutdown(context.Background())
+	ta.shutdownFuncs = nil
+	if calls != 3 {
+		t.Errorf("ran %d shutdown functions, want 3", calls)
+	}
+	var shutdownErr *ShutdownError
+	if !errors.As(err, &shutdownErr) || len(shutdownErr.Errors) != 2 {
+		t.Fatalf("Shutdown returned %v, want the 2 failures", err)
+	}
+	if shutdownErr.Errors[0] != errFirst || shutdownErr.Errors[1] != errSecond {
+		t.Errorf("Shutdown returned %v, want both failures in order", shutdownErr.Errors)
+	}
+}
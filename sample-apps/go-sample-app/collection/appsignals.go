@@ -0,0 +1,237 @@
+package collection
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Contains the span attributes and metrics expected by CloudWatch Application Signals.
+
+// Application Signals span attributes.
+const (
+	awsSpanKindKey        = attribute.Key("aws.span.kind")
+	awsLocalServiceKey    = attribute.Key("aws.local.service")
+	awsLocalOperationKey  = attribute.Key("aws.local.operation")
+	awsRemoteServiceKey   = attribute.Key("aws.remote.service")
+	awsRemoteOperationKey = attribute.Key("aws.remote.operation")
+)
+
+// Values of aws.span.kind besides the span kinds.
+const (
+	awsSpanKindLocalRoot = "LOCAL_ROOT"
+	internalOperation    = "InternalOperation"
+	unknownRemoteService = "UnknownRemoteService"
+	unknownRemoteOp      = "UnknownRemoteOperation"
+)
+
+// appSignalsProcessor adds the aws.* Application Signals attributes to every span when it starts and records the
+// latency, error and fault metrics of the service and of its dependencies when it ends. The local operation of a
+// server span is its method and route, and is inherited by the spans started below it.
+type appSignalsProcessor struct {
+	// serviceName is the local service when the resource has no service name.
+	serviceName string
+	// operations holds the local operation of every live span, by span ID.
+	operations sync.Map
+	latency    instrument.Float64Histogram
+	errors     instrument.Int64Histogram
+	faults     instrument.Int64Histogram
+}
+
+// newAppSignalsProcessor creates the Application Signals metrics. nameSuffix is appended to the metric names and
+// serviceName is the local service of spans whose resource has no service name.
+func newAppSignalsProcessor(mp metric.MeterProvider, nameSuffix, serviceName string) (*appSignalsProcessor, error) {
+	meter := mp.Meter(instrumentationName)
+	p := &appSignalsProcessor{serviceName: serviceName}
+	var err error
+	if p.latency, err = meter.Float64Histogram(
+		appSignalsLatency+nameSuffix,
+		instrument.WithDescription("Measures the latency of the operations and of their dependencies"),
+		instrument.WithUnit("ms"),
+	); err != nil {
+		return nil, err
+	}
+	if p.errors, err = meter.Int64Histogram(
+		appSignalsError+nameSuffix,
+		instrument.WithDescription("Records 1 for every operation or dependency call ending in a client error, 0 otherwise"),
+		instrument.WithUnit("1"),
+	); err != nil {
+		return nil, err
+	}
+	if p.faults, err = meter.Int64Histogram(
+		appSignalsFault+nameSuffix,
+		instrument.WithDescription("Records 1 for every operation or dependency call ending in a server error, 0 otherwise"),
+		instrument.WithUnit("1"),
+	); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *appSignalsProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range s.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	parentSC := trace.SpanContextFromContext(parent)
+	localRoot := !parentSC.IsValid() || parentSC.IsRemote()
+
+	var operation string
+	if s.SpanKind() == trace.SpanKindServer {
+		operation = serverOperation(attrs)
+	} else if !localRoot {
+		if op, ok := p.operations.Load(parentSC.SpanID()); ok {
+			operation = op.(string)
+		}
+	}
+	if operation == "" {
+		operation = internalOperation
+	}
+	p.operations.Store(s.SpanContext().SpanID(), operation)
+
+	kind := strings.ToUpper(s.SpanKind().String())
+	if localRoot {
+		kind = awsSpanKindLocalRoot
+	}
+	service := serviceNameOf(s)
+	if strings.HasPrefix(service, "unknown_service") {
+		service = p.serviceName
+	}
+	s.SetAttributes(
+		awsSpanKindKey.String(kind),
+		awsLocalServiceKey.String(service),
+		awsLocalOperationKey.String(operation),
+	)
+	if isDependency(s.SpanKind()) {
+		service, op := remoteTarget(attrs)
+		s.SetAttributes(awsRemoteServiceKey.String(service), awsRemoteOperationKey.String(op))
+	}
+}
+
+func (p *appSignalsProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	p.operations.Delete(s.SpanContext().SpanID())
+
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range s.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	latency := float64(s.EndTime().Sub(s.StartTime()).Microseconds()) / 1000
+	isError, isFault := spanOutcome(s, attrs)
+	ctx := context.Background()
+
+	record := func(dims ...attribute.KeyValue) {
+		p.latency.Record(ctx, latency, dims...)
+		p.errors.Record(ctx, isError, dims...)
+		p.faults.Record(ctx, isFault, dims...)
+	}
+	local := []attribute.KeyValue{
+		awsLocalServiceKey.String(attrs[awsLocalServiceKey].AsString()),
+		awsLocalOperationKey.String(attrs[awsLocalOperationKey].AsString()),
+	}
+	// Service metrics for the operations, dependency metrics for the calls
+	if s.SpanKind() == trace.SpanKindServer || attrs[awsSpanKindKey].AsString() == awsSpanKindLocalRoot {
+		record(append(local, awsSpanKindKey.String("SERVER"))...)
+	}
+	if isDependency(s.SpanKind()) {
+		record(append(local,
+			awsSpanKindKey.String(strings.ToUpper(s.SpanKind().String())),
+			awsRemoteServiceKey.String(attrs[awsRemoteServiceKey].AsString()),
+			awsRemoteOperationKey.String(attrs[awsRemoteOperationKey].AsString()),
+		)...)
+	}
+}
+
+func (p *appSignalsProcessor) Shutdown(context.Context) error { return nil }
+
+func (p *appSignalsProcessor) ForceFlush(context.Context) error { return nil }
+
+// isDependency reports whether spans of kind call another service.
+func isDependency(kind trace.SpanKind) bool {
+	return kind == trace.SpanKindClient || kind == trace.SpanKindProducer || kind == trace.SpanKindConsumer
+}
+
+// serverOperation returns the method and route of a server span, e.g. GET /db-call.
+func serverOperation(attrs map[attribute.Key]attribute.Value) string {
+	route := attrs[semconv.HTTPRouteKey].AsString()
+	if route == "" {
+		route = attrs[semconv.HTTPTargetKey].AsString()
+		if i := strings.IndexByte(route, '?'); i >= 0 {
+			route = route[:i]
+		}
+	}
+	if method := attrs[semconv.HTTPMethodKey].AsString(); method != "" && route != "" {
+		return method + " " + route
+	}
+	if service, method := attrs[semconv.RPCServiceKey].AsString(), attrs[semconv.RPCMethodKey].AsString(); method != "" {
+		return service + "/" + method
+	}
+	return ""
+}
+
+// remoteTarget returns the service and operation called by a client, producer or consumer span, from the
+// attributes of AWS SDK, database, messaging, RPC and HTTP calls.
+func remoteTarget(attrs map[attribute.Key]attribute.Value) (string, string) {
+	switch {
+	case attrs[semconv.RPCSystemKey].AsString() == "aws-api":
+		return "AWS::" + attrs[semconv.RPCServiceKey].AsString(), attrs[semconv.RPCMethodKey].AsString()
+	case attrs[semconv.DBSystemKey].AsString() != "":
+		return attrs[semconv.DBSystemKey].AsString(), attrs[semconv.DBOperationKey].AsString()
+	case attrs[semconv.MessagingSystemKey].AsString() != "":
+		system := attrs[semconv.MessagingSystemKey].AsString()
+		if system == "aws_sqs" {
+			system = "AWS::SQS"
+		}
+		return system, attrs[semconv.MessagingOperationKey].AsString()
+	case attrs[semconv.RPCSystemKey].AsString() != "":
+		return attrs[semconv.RPCServiceKey].AsString(), attrs[semconv.RPCMethodKey].AsString()
+	case attrs[semconv.HTTPURLKey].AsString() != "":
+		if u, err := url.Parse(attrs[semconv.HTTPURLKey].AsString()); err == nil && u.Host != "" {
+			return httpRemoteTarget(attrs[semconv.HTTPMethodKey].AsString(), u)
+		}
+	}
+	return unknownRemoteService, unknownRemoteOp
+}
+
+// httpRemoteTarget returns the host of u as the remote service and the method with the first segment of the path
+// as the remote operation, since full paths may hold IDs.
+func httpRemoteTarget(method string, u *url.URL) (string, string) {
+	operation := "/"
+	if segments := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2); segments[0] != "" {
+		operation += segments[0]
+	}
+	return u.Host, method + " " + operation
+}
+
+// appSignalsTransport sets the remote service and operation on the client span of outgoing HTTP requests, whose
+// attributes otelhttp only sets after the span has started.
+type appSignalsTransport struct {
+	base http.RoundTripper
+}
+
+func (t appSignalsTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	service, operation := httpRemoteTarget(r.Method, r.URL)
+	trace.SpanFromContext(r.Context()).SetAttributes(awsRemoteServiceKey.String(service), awsRemoteOperationKey.String(operation))
+	return t.base.RoundTrip(r)
+}
+
+// spanOutcome returns 1 as error for client errors, and 1 as fault for server errors and failed spans.
+func spanOutcome(s sdktrace.ReadOnlySpan, attrs map[attribute.Key]attribute.Value) (isError int64, isFault int64) {
+	status := attrs[semconv.HTTPStatusCodeKey].AsInt64()
+	switch {
+	case status >= 400 && status < 500:
+		return 1, 0
+	case status >= 500 || s.Status().Code == codes.Error:
+		return 0, 1
+	}
+	return 0, 0
+}
@@ -0,0 +1,155 @@
+package collection
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spanAttr returns the value of key on span, or an empty value.
+func spanAttr(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+// histogramPoint returns the data point of the histogram m with the attributes dims.
+func histogramPoint(t *testing.T, m metricdata.Metrics, dims ...attribute.KeyValue) (metricdata.HistogramDataPoint, bool) {
+	t.Helper()
+	hist, ok := m.Data.(metricdata.Histogram)
+	if !ok {
+		t.Fatalf("%s is a %T, want a histogram", m.Name, m.Data)
+	}
+	want := attribute.NewSet(dims...)
+	for _, dp := range hist.DataPoints {
+		if dp.Attributes.Equals(&want) {
+			return dp, true
+		}
+	}
+	return metricdata.HistogramDataPoint{}, false
+}
+
+func TestAppSignals(t *testing.T) {
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer downstream.Close()
+	downstreamURL, _ := url.Parse(downstream.URL)
+
+	cfg := testConfig(t)
+	cfg.ServiceName = "appsignals-test"
+	cfg.ApplicationSignals.Enabled = true
+	cfg.Endpoints = []EndpointConfig{
+		{Path: "/ok", Calls: []EndpointCallConfig{{Url: downstream.URL + "/orders/42"}}},
+		{Path: "/missing", ErrorRate: 1, ErrorStatus: http.StatusNotFound},
+		{Path: "/broken", ErrorRate: 1, ErrorStatus: http.StatusServiceUnavailable},
+	}
+	ta := newTestApp(t, cfg)
+
+	for target, status := range map[string]int{"/ok": 200, "/missing": 404, "/broken": 503} {
+		if rec := ta.serve(t, http.MethodGet, target); rec.Code != status {
+			t.Fatalf("GET %s = %d, want %d", target, rec.Code, status)
+		}
+	}
+
+	t.Run("spans", func(t *testing.T) {
+		var server, internal, client int
+		for _, span := range ta.spans.GetSpans() {
+			if got := spanAttr(span, awsLocalServiceKey).AsString(); got != "appsignals-test" {
+				t.Errorf("%s: aws.local.service = %q, want appsignals-test", span.Name, got)
+			}
+			operation := spanAttr(span, awsLocalOperationKey).AsString()
+			switch span.SpanKind {
+			case trace.SpanKindServer:
+				server++
+				if got := spanAttr(span, awsSpanKindKey).AsString(); got != awsSpanKindLocalRoot {
+					t.Errorf("%s: aws.span.kind = %q, want %s", span.Name, got, awsSpanKindLocalRoot)
+				}
+				if want := "GET " + span.Name; operation != want {
+					t.Errorf("%s: aws.local.operation = %q, want %q", span.Name, operation, want)
+				}
+			case trace.SpanKindInternal:
+				internal++
+				if want := "GET " + span.Name; operation != want {
+					t.Errorf("%s: aws.local.operation = %q, want the inherited %q", span.Name, operation, want)
+				}
+			case trace.SpanKindClient:
+				client++
+				if operation != "GET /ok" {
+					t.Errorf("%s: aws.local.operation = %q, want GET /ok", span.Name, operation)
+				}
+				if got := spanAttr(span, awsRemoteServiceKey).AsString(); got != downstreamURL.Host {
+					t.Errorf("%s: aws.remote.service = %q, want %q", span.Name, got, downstreamURL.Host)
+				}
+				if got := spanAttr(span, awsRemoteOperationKey).AsString(); got != "GET /orders" {
+					t.Errorf("%s: aws.remote.operation = %q, want GET /orders", span.Name, got)
+				}
+			}
+		}
+		if server != 3 || internal != 3 || client != 1 {
+			t.Errorf("recorded %d server, %d internal and %d client spans, want 3, 3 and 1", server, internal, client)
+		}
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		metrics := ta.metrics(t)
+		service := func(operation string) []attribute.KeyValue {
+			return []attribute.KeyValue{
+				awsLocalServiceKey.String("appsignals-test"),
+				awsLocalOperationKey.String(operation),
+				awsSpanKindKey.String("SERVER"),
+			}
+		}
+		tests := []struct {
+			name                 string
+			dims                 []attribute.KeyValue
+			wantError, wantFault float64
+		}{
+			{"2xx", service("GET /ok"), 0, 0},
+			{"4xx", service("GET /missing"), 1, 0},
+			{"5xx", service("GET /broken"), 0, 1},
+			{"dependency", []attribute.KeyValue{
+				awsLocalServiceKey.String("appsignals-test"),
+				awsLocalOperationKey.String("GET /ok"),
+				awsSpanKindKey.String("CLIENT"),
+				awsRemoteServiceKey.String(downstreamURL.Host),
+				awsRemoteOperationKey.String("GET /orders"),
+			}, 0, 0},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				latency, ok := histogramPoint(t, metrics[appSignalsLatency], tt.dims...)
+				if !ok || latency.Count != 1 {
+					t.Fatalf("latency has no single data point with %v", tt.dims)
+				}
+				if latency.Sum <= 0 {
+					t.Errorf("latency sum = %v, want > 0", latency.Sum)
+				}
+				errs, _ := histogramPoint(t, metrics[appSignalsError], tt.dims...)
+				faults, _ := histogramPoint(t, metrics[appSignalsFault], tt.dims...)
+				if errs.Count != 1 || errs.Sum != tt.wantError {
+					t.Errorf("error count, sum = %d, %v, want 1, %v", errs.Count, errs.Sum, tt.wantError)
+				}
+				if faults.Count != 1 || faults.Sum != tt.wantFault {
+					t.Errorf("fault count, sum = %d, %v, want 1, %v", faults.Count, faults.Sum, tt.wantFault)
+				}
+			})
+		}
+	})
+}
+
+func TestHTTPRemoteTarget(t *testing.T) {
+	u, _ := url.Parse("http://" + net.JoinHostPort("example.com", "8080") + "/users/42/orders")
+	service, operation := httpRemoteTarget(http.MethodPost, u)
+	if service != "example.com:8080" || operation != "POST /users" {
+		t.Errorf("httpRemoteTarget() = %q, %q, want example.com:8080, POST /users", service, operation)
+	}
+}
@@ -0,0 +1,57 @@
+package collection
+
+import (
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// Contains the configurable common attributes of the signals.
+
+// protectedAttributeKeys are the common attributes required by the spec, or identifying the instance, which the
+// configured attributes cannot override.
+var protectedAttributeKeys = []string{"signal", "language", "metricType", "host", "port", string(semconv.ServiceInstanceIDKey)}
+
+// isProtectedAttribute reports whether key is one of the protected attribute keys.
+func isProtectedAttribute(key string) bool {
+	for _, k := range protectedAttributeKeys {
+		if key == k {
+			return true
+		}
+	}
+	return false
+}
+
+// keyValueAttributes parses key=value pairs into string attributes.
+func keyValueAttributes(pairs []string) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(pairs))
+	for _, pair := range pairs {
+		key, value, _ := strings.Cut(pair, "=")
+		attrs = append(attrs, attribute.String(strings.TrimSpace(key), strings.TrimSpace(value)))
+	}
+	return attrs
+}
+
+// commonAttributes returns the configured common attributes of a signal, in order of cfgs. Static attributes are
+// used as is, attributes read from an unset environment variable are left out and protected keys are dropped.
+func commonAttributes(cfgs ...AttributesConfig) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	for _, cfg := range cfgs {
+		attrs = append(attrs, keyValueAttributes(cfg.Static)...)
+		for _, kv := range keyValueAttributes(cfg.FromEnv) {
+			if value, ok := os.LookupEnv(kv.Value.AsString()); ok {
+				attrs = append(attrs, attribute.String(string(kv.Key), value))
+			}
+		}
+	}
+
+	kept := attrs[:0]
+	for _, kv := range attrs {
+		if !isProtectedAttribute(string(kv.Key)) {
+			kept = append(kept, kv)
+		}
+	}
+	return kept
+}
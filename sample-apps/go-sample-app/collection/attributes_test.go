@@ -0,0 +1,43 @@
+package collection
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestCommonAttributes(t *testing.T) {
+	t.Setenv("TEST_REGION", "eu-west-1")
+	t.Setenv("TEST_HOST", "spoofed")
+
+	got := commonAttributes(
+		AttributesConfig{
+			Static:  []string{"team=payments", "signal=spoofed", " env = prod "},
+			FromEnv: []string{"region=TEST_REGION", "zone=TEST_UNSET", "host=TEST_HOST"},
+		},
+		AttributesConfig{Static: []string{"service.instance.id=spoofed", "tier=gold"}},
+	)
+
+	want := []attribute.KeyValue{
+		attribute.String("team", "payments"),
+		attribute.String("env", "prod"),
+		attribute.String("region", "eu-west-1"),
+		attribute.String("tier", "gold"),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("commonAttributes() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("attribute %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCommonAttributesProtectedKeys(t *testing.T) {
+	for _, key := range protectedAttributeKeys {
+		if attrs := commonAttributes(AttributesConfig{Static: []string{key + "=x"}}); len(attrs) != 0 {
+			t.Errorf("the protected key %s was kept: %v", key, attrs)
+		}
+	}
+}
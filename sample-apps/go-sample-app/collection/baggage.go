@@ -0,0 +1,41 @@
+package collection
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Contains the logic turning W3C baggage entries into telemetry attributes.
+
+// baggageAttributes returns the baggage entries of ctx whose key is in allowList as attributes.
+func baggageAttributes(ctx context.Context, allowList []string) []attribute.KeyValue {
+	if len(allowList) == 0 {
+		return nil
+	}
+	bag := baggage.FromContext(ctx)
+	var attrs []attribute.KeyValue
+	for _, key := range allowList {
+		if member := bag.Member(key); member.Key() != "" {
+			attrs = append(attrs, attribute.String(key, member.Value()))
+		}
+	}
+	return attrs
+}
+
+// baggageSpanProcessor adds the allowed baggage entries of the parent context to every span when it starts.
+type baggageSpanProcessor struct {
+	allowList []string
+}
+
+func (p baggageSpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	s.SetAttributes(baggageAttributes(parent, p.allowList)...)
+}
+
+func (p baggageSpanProcessor) OnEnd(sdktrace.ReadOnlySpan) {}
+
+func (p baggageSpanProcessor) Shutdown(context.Context) error { return nil }
+
+func (p baggageSpanProcessor) ForceFlush(context.Context) error { return nil }
@@ -2,13 +2,13 @@ package collection
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"time"
 
-	"go.opentelemetry.io/contrib/propagators/aws/xray"
-	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/sdk/metric/aggregation"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
@@ -18,14 +18,8 @@ import (
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
-var cfg = GetConfiguration()
-
 const serviceName = "go"
 
-var testingId = ""
-
-var tracer = otel.Tracer("github.com/aws-otel-commnunity/sample-apps/go-sample-app/collection")
-
 // Names for metric instruments
 const timeAlive = "time_alive"
 const cpuUsage = "cpu_usage"
@@ -34,8 +28,17 @@ const threadsActive = "threads_active"
 const totalBytesSent = "total_bytes_sent"
 const totalApiRequests = "total_api_requests"
 const latencyTime = "latency_time"
-
-// Common attributes for traces and metrics (random, request)
+const dbQueryDuration = "db_query_duration"
+const queueDepth = "queue_depth"
+const consumerLag = "consumer_lag"
+const jobDuration = "job_duration"
+const jobRuns = "job_runs"
+const appSignalsLatency = "latency"
+const appSignalsError = "error"
+const appSignalsFault = "fault"
+
+// Common attributes for metrics (random, request). Common attributes for traces depend on the configuration and
+// are held by the App.
 var requestMetricCommonLabels = []attribute.KeyValue{
 	attribute.String("signal", "metric"),
 	attribute.String("language", serviceName),
@@ -48,87 +51,127 @@ var randomMetricCommonLabels = []attribute.KeyValue{
 	attribute.String("metricType", "random"),
 }
 
-var traceCommonLabels = []attribute.KeyValue{
-	attribute.String("signal", "trace"),
-	attribute.String("language", serviceName),
-	attribute.String("host", cfg.Host),
-	attribute.String("port", cfg.Port),
-}
-
-// StartClient starts the traces and metrics providers which periodically collects signals and exports them.
-// Trace exporter and Metric exporter are both configured.
-func StartClient(ctx context.Context) (func(context.Context) error, error) {
-
-	if id, present := os.LookupEnv("INSTANCE_ID"); present {
-		testingId = "_" + id
+// startClient starts the traces and metrics providers which periodically collects signals and exports them.
+// Trace exporter and Metric exporter are both configured, and the request log exporter when LogExporter is set.
+// Only the providers that were not supplied as options are started. The resource holds the configured service name
+// and the instance ID, OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence.
+func (a *App) startClient(ctx context.Context) error {
+	attrs := []attribute.KeyValue{semconv.ServiceName(a.cfg.ServiceName)}
+	if a.instanceId != "" {
+		attrs = append(attrs, semconv.ServiceInstanceID(a.instanceId))
 	}
-	res := resource.NewWithAttributes(
-		semconv.SchemaURL,
-		semconv.ServiceName("go-sample-app"),
-	)
-	if _, present := os.LookupEnv("OTEL_RESOURCE_ATTRIBUTES"); present {
+	res := resource.NewWithAttributes(semconv.SchemaURL, attrs...)
+	_, hasAttributes := os.LookupEnv("OTEL_RESOURCE_ATTRIBUTES")
+	_, hasServiceName := os.LookupEnv("OTEL_SERVICE_NAME")
+	if hasAttributes || hasServiceName {
 		envResource, err := resource.New(ctx, resource.WithFromEnv())
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if res, err = resource.Merge(res, envResource); err != nil {
+			return err
 		}
-		res = envResource
 	}
-
-	// Setup trace related
-	tp, err := setupTraceProvider(ctx, res)
-	if err != nil {
-		return nil, err
+	if len(a.detectors) > 0 {
+		// Detectors report an error when they do not apply to the environment, detected attributes are still kept
+		detected, err := resource.New(ctx, resource.WithDetectors(a.detectors...))
+		if err != nil {
+			fmt.Println(err)
+		}
+		if detected != nil {
+			merged, err := resource.Merge(res, detected)
+			if err != nil {
+				return err
+			}
+			res = merged
+		}
 	}
+	a.resource = res
 
-	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(xray.Propagator{}) // Set AWS X-Ray propagator
-
-	exp, err := otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithInsecure())
-	if err != nil {
-		return nil, err
+	if a.cfg.LogExporter == logExporterSigV4 {
+		logs, err := newLogEmitter(a.cfg.SigV4, res, a.logStatus)
+		if err != nil {
+			return err
+		}
+		a.logs = logs
+		a.shutdownFuncs = append(a.shutdownFuncs, logs.Shutdown)
 	}
-	meterProvider := metric.NewMeterProvider(metric.WithResource(res), metric.WithReader(metric.NewPeriodicReader(exp)), metric.WithView(metric.NewView(
-		metric.Instrument{Name: "mp_histogram"},
-		metric.Stream{Aggregation: aggregation.ExplicitBucketHistogram{
-			Boundaries: []float64{100, 300, 500},
-		}},
-	)),)
 
-	otel.SetMeterProvider(meterProvider)
-
-	return func(context.Context) (err error) {
-		ctx, cancel := context.WithTimeout(ctx, time.Second)
-		defer cancel()
-
-		// pushes any last exports to the receiver
-		err = meterProvider.Shutdown(ctx)
+	// Setup trace related
+	if a.tp == nil {
+		tp, err := a.setupTraceProvider(ctx, res)
 		if err != nil {
 			return err
 		}
-		err = tp.Shutdown(ctx)
+		a.tp = tp
+		a.shutdownFuncs = append(a.shutdownFuncs, tp.Shutdown)
+	}
+
+	if a.mp == nil {
+		exp, err := otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithInsecure())
 		if err != nil {
 			return err
 		}
-		return nil
-	}, nil
+		a.debugReader = metric.NewManualReader()
+		meterProvider := metric.NewMeterProvider(metric.WithResource(res), metric.WithReader(metric.NewPeriodicReader(statusMetricExporter{semconvMetricExporter{exp, a.semconv}, a.metricStatus})), metric.WithReader(a.debugReader), metric.WithView(metric.NewView(
+			metric.Instrument{Name: "mp_histogram"},
+			metric.Stream{Aggregation: aggregation.ExplicitBucketHistogram{
+				Boundaries: []float64{100, 300, 500},
+			}},
+		)))
+		a.mp = meterProvider
+		a.metricProbe = &probeTarget{network: "tcp", addr: collectorEndpoint("METRICS")}
+
+		// pushes any last exports to the receiver before the trace provider is shut down
+		a.shutdownFuncs = append([]func(context.Context) error{meterProvider.Shutdown}, a.shutdownFuncs...)
+	}
+	return nil
 }
 
-// setupTraceProvider configures a trace exporter and an AWS X-Ray ID Generator.
-func setupTraceProvider(ctx context.Context, res *resource.Resource) (*sdktrace.TracerProvider, error) {
-	// INSECURE !! NOT TO BE USED FOR ANYTHING IN PRODUCTION
-	traceExporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithInsecure())
+// setupTraceProvider configures a trace exporter and an AWS X-Ray ID Generator. Spans are exported over OTLP, to the
+// X-Ray daemon when TraceExporter is xray, or with OTLP/HTTP signed with SigV4 when it is sigv4. Their HTTP attributes
+// follow the semantic conventions of SemconvStability.
+func (a *App) setupTraceProvider(ctx context.Context, res *resource.Resource) (*sdktrace.TracerProvider, error) {
+	var (
+		traceExporter sdktrace.SpanExporter
+		batchOptions  []sdktrace.BatchSpanProcessorOption
+		err           error
+	)
+	switch a.cfg.TraceExporter {
+	case traceExporterXray:
+		traceExporter, err = NewXrayDaemonExporter(a.cfg.XrayDaemon.Address)
+		a.traceProbe = &probeTarget{network: "udp", addr: xrayDaemonAddress(a.cfg.XrayDaemon.Address)}
+		batchOptions = append(batchOptions,
+			sdktrace.WithMaxExportBatchSize(a.cfg.XrayDaemon.BatchSize),
+			sdktrace.WithBatchTimeout(time.Duration(a.cfg.XrayDaemon.BatchTimeout)*time.Millisecond),
+		)
+	case traceExporterSigV4:
+		var client *sigv4TraceClient
+		if client, err = newSigV4TraceClient(a.cfg.SigV4); err == nil {
+			traceExporter, err = otlptrace.New(ctx, client)
+			a.traceProbe = &probeTarget{network: "tcp", addr: endpointAddress(client.poster.endpoint)}
+		}
+	default:
+		// INSECURE !! NOT TO BE USED FOR ANYTHING IN PRODUCTION
+		traceExporter, err = otlptracegrpc.New(ctx, otlptracegrpc.WithInsecure())
+		a.traceProbe = &probeTarget{network: "tcp", addr: collectorEndpoint("TRACES")}
+	}
 
 	if err != nil {
 		return nil, err
 	}
 
-	idg := xray.NewIDGenerator()
-
-	tp := sdktrace.NewTracerProvider(
+	opts := []sdktrace.TracerProviderOption{
 		sdktrace.WithSampler(sdktrace.AlwaysSample()),
-		sdktrace.WithBatcher(traceExporter),
+		sdktrace.WithBatcher(statusSpanExporter{semconvSpanExporter{traceExporter, a.semconv}, a.traceStatus}, batchOptions...),
 		sdktrace.WithResource(res),
-		sdktrace.WithIDGenerator(idg),
-	)
+		sdktrace.WithIDGenerator(a.ids),
+		sdktrace.WithSpanProcessor(a.spanCounter),
+		sdktrace.WithSpanProcessor(baggageSpanProcessor{allowList: a.cfg.Baggage.AllowList}),
+	}
+	if a.annotator != nil {
+		opts = append(opts, sdktrace.WithSpanProcessor(a.annotator))
+	}
+	tp := sdktrace.NewTracerProvider(opts...)
 	return tp, nil
-}
\ No newline at end of file
+}
@@ -1,38 +1,454 @@
 package collection
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
+// DefaultConfigFile is the configuration file read when SAMPLE_APP_CONF is not set.
+const DefaultConfigFile = "config.yaml"
+
+// Formats of the trace ID returned in the endpoint responses.
+const (
+	traceIdFormatXray = "xray"
+	traceIdFormatW3C  = "w3c"
+)
+
+// EnvPrefix is the prefix of the environment variables overriding configuration keys, e.g. SAMPLE_APP_PORT or
+// SAMPLE_APP_SYNTHETICMETRICS_COUNT.
+const EnvPrefix = "SAMPLE_APP"
+
 // Config contains random based metrics; values inputed by configuration file or defaulted values
 type Config struct {
-	Host                    string   `mapstructure:"Host"`
-	Port                    string   `mapstructure:"Port"`
-	TimeInterval            int64    `mapstructure:"TimeInterval"`
-	TimeAliveIncrementer    int64    `mapstructure:"RandomTimeAliveIncrementer"`
-	TotalHeapSizeUpperBound int64    `mapstructure:"RandomTotalHeapSizeUpperBound"`
-	ThreadsActiveUpperBound int64    `mapstructure:"RandomThreadsActiveUpperBound"`
-	CpuUsageUpperBound      int64    `mapstructure:"RandomCpuUsageUpperBound"`
-	SampleAppPorts          []string `mapstructure:"SampleAppPorts"`
+	ServiceName             string                        `mapstructure:"ServiceName" yaml:"ServiceName"`
+	InstanceId              string                        `mapstructure:"InstanceId" yaml:"InstanceId"`
+	MetricNameSuffix        bool                          `mapstructure:"MetricNameSuffix" yaml:"MetricNameSuffix"`
+	Seed                    int64                         `mapstructure:"Seed" yaml:"Seed"`
+	Host                    string                        `mapstructure:"Host" yaml:"Host"`
+	Port                    string                        `mapstructure:"Port" yaml:"Port"`
+	TimeInterval            int64                         `mapstructure:"TimeInterval" yaml:"TimeInterval"`
+	TimeAliveIncrementer    int64                         `mapstructure:"RandomTimeAliveIncrementer" yaml:"RandomTimeAliveIncrementer"`
+	TotalHeapSizeUpperBound int64                         `mapstructure:"RandomTotalHeapSizeUpperBound" yaml:"RandomTotalHeapSizeUpperBound"`
+	ThreadsActiveUpperBound int64                         `mapstructure:"RandomThreadsActiveUpperBound" yaml:"RandomThreadsActiveUpperBound"`
+	CpuUsageUpperBound      int64                         `mapstructure:"RandomCpuUsageUpperBound" yaml:"RandomCpuUsageUpperBound"`
+	SampleAppPorts          []string                      `mapstructure:"SampleAppPorts" yaml:"SampleAppPorts"`
+	DebugToken              string                        `mapstructure:"DebugToken" yaml:"DebugToken" json:"DebugToken,omitempty"`
+	SyntheticMetrics        SyntheticMetricsConfig        `mapstructure:"SyntheticMetrics" yaml:"SyntheticMetrics"`
+	RequestMetricAttributes RequestMetricAttributesConfig `mapstructure:"RequestMetricAttributes" yaml:"RequestMetricAttributes"`
+	Baggage                 BaggageConfig                 `mapstructure:"Baggage" yaml:"Baggage"`
+	CommonAttributes        CommonAttributesConfig        `mapstructure:"CommonAttributes" yaml:"CommonAttributes"`
+	TraceResponseHeaders    bool                          `mapstructure:"TraceResponseHeaders" yaml:"TraceResponseHeaders"`
+	ResponseTraceIdFormat   string                        `mapstructure:"ResponseTraceIdFormat" yaml:"ResponseTraceIdFormat"`
+	GrpcPort                string                        `mapstructure:"GrpcPort" yaml:"GrpcPort"`
+	SampleAppProtocol       string                        `mapstructure:"SampleAppProtocol" yaml:"SampleAppProtocol"`
+	Database                DatabaseConfig                `mapstructure:"Database" yaml:"Database"`
+	Messaging               MessagingConfig               `mapstructure:"Messaging" yaml:"Messaging"`
+	Jobs                    []JobConfig                   `mapstructure:"Jobs" yaml:"Jobs"`
+	Endpoints               []EndpointConfig              `mapstructure:"Endpoints" yaml:"Endpoints"`
+	TraceExporter           string                        `mapstructure:"TraceExporter" yaml:"TraceExporter"`
+	XrayDaemon              XrayDaemonConfig              `mapstructure:"XrayDaemon" yaml:"XrayDaemon"`
+	LogExporter             string                        `mapstructure:"LogExporter" yaml:"LogExporter"`
+	SigV4                   SigV4Config                   `mapstructure:"SigV4" yaml:"SigV4"`
+	XrayAnnotations         XrayAnnotationsConfig         `mapstructure:"XrayAnnotations" yaml:"XrayAnnotations"`
+	ApplicationSignals      ApplicationSignalsConfig      `mapstructure:"ApplicationSignals" yaml:"ApplicationSignals"`
+	SemconvStability        string                        `mapstructure:"SemconvStability" yaml:"SemconvStability"`
+
+	// unknownKeys are the keys of the configuration file that do not match any setting, reported by Validate.
+	unknownKeys []string
+	// decodeErrors are the values that could not be decoded into their setting, reported by Validate.
+	decodeErrors []string
 }
 
-// GetConfiguration returns a configured Config struct with the precedence; Default Values < Configuration File.
-func GetConfiguration() *Config {
-	var arr []string
-	viper.SetDefault("Host", "0.0.0.0")
-	viper.SetDefault("Port", "8080")
-	viper.SetDefault("TimeInterval", 1)
-	viper.SetDefault("RandomTimeAliveIncrementer", 1)
-	viper.SetDefault("RandomTotalHeapSizeUpperBound", 100)
-	viper.SetDefault("RandomThreadsActiveUpperBound", 10)
-	viper.SetDefault("RandomCpuUsageUpperBound", 100)
-	viper.SetDefault("SampleAppPorts", arr)
-
-	viper.SetConfigFile("config.yaml")
-	viper.ReadInConfig()
-
-	cfg := &Config{}
-	viper.Unmarshal(cfg)
+// SyntheticMetricsConfig configures the synthetic metric generator used for load testing. No synthetic instruments
+// are created when Count is 0.
+type SyntheticMetricsConfig struct {
+	Count          int      `mapstructure:"Count" yaml:"Count"`
+	Kinds          []string `mapstructure:"Kinds" yaml:"Kinds"`
+	AttributeCount int      `mapstructure:"AttributeCount" yaml:"AttributeCount"`
+	Cardinality    int      `mapstructure:"Cardinality" yaml:"Cardinality"`
+	Interval       int64    `mapstructure:"Interval" yaml:"Interval"`
+}
+
+// RequestMetricAttributesConfig enables optional attributes on the request based metrics, in addition to the common
+// attributes required by the spec.
+type RequestMetricAttributesConfig struct {
+	Route      bool `mapstructure:"Route" yaml:"Route"`
+	Method     bool `mapstructure:"Method" yaml:"Method"`
+	StatusCode bool `mapstructure:"StatusCode" yaml:"StatusCode"`
+}
+
+// BaggageConfig selects the incoming W3C baggage entries that become span attributes and, optionally, request based
+// metric attributes. Baggage is propagated on every outgoing call.
+type BaggageConfig struct {
+	AllowList        []string `mapstructure:"AllowList" yaml:"AllowList"`
+	MetricAttributes bool     `mapstructure:"MetricAttributes" yaml:"MetricAttributes"`
+}
+
+// CommonAttributesConfig adds attributes to the spans and metrics of the sample app, next to the common attributes
+// required by the spec, which cannot be overridden. All applies to every signal.
+type CommonAttributesConfig struct {
+	All     AttributesConfig `mapstructure:"All" yaml:"All"`
+	Traces  AttributesConfig `mapstructure:"Traces" yaml:"Traces"`
+	Metrics AttributesConfig `mapstructure:"Metrics" yaml:"Metrics"`
+}
+
+// AttributesConfig lists attributes as key=value pairs. The value of a Static attribute is used as is, the value of
+// a FromEnv attribute names the environment variable holding the value; it is left out when the variable is unset.
+type AttributesConfig struct {
+	Static  []string `mapstructure:"Static" yaml:"Static"`
+	FromEnv []string `mapstructure:"FromEnv" yaml:"FromEnv"`
+}
+
+// DatabaseConfig configures the embedded database queried by the /db-call endpoint.
+type DatabaseConfig struct {
+	Enabled            bool     `mapstructure:"Enabled" yaml:"Enabled"`
+	Rows               int      `mapstructure:"Rows" yaml:"Rows"`
+	SelectRows         int      `mapstructure:"SelectRows" yaml:"SelectRows"`
+	QueryMix           []string `mapstructure:"QueryMix" yaml:"QueryMix"`
+	SanitizeStatements bool     `mapstructure:"SanitizeStatements" yaml:"SanitizeStatements"`
+}
+
+// MessagingConfig configures the queue used by the /publish endpoint and its background consumers. Queue is memory
+// for an in-process queue or sqs for an Amazon SQS (or SQS compatible) queue.
+type MessagingConfig struct {
+	Queue          string `mapstructure:"Queue" yaml:"Queue"`
+	QueueUrl       string `mapstructure:"QueueUrl" yaml:"QueueUrl"`
+	SqsEndpoint    string `mapstructure:"SqsEndpoint" yaml:"SqsEndpoint"`
+	Capacity       int    `mapstructure:"Capacity" yaml:"Capacity"`
+	Consumers      int    `mapstructure:"Consumers" yaml:"Consumers"`
+	ProcessingTime int64  `mapstructure:"ProcessingTime" yaml:"ProcessingTime"`
+}
+
+// JobConfig configures a scheduled background job. Schedule is a cron spec with an optional seconds field or a
+// descriptor such as @every 30s, Duration is the simulated work in milliseconds and Calls are URLs called after the
+// work. OverlapPolicy is allow, skip (default) or delay.
+type JobConfig struct {
+	Name          string   `mapstructure:"Name" yaml:"Name"`
+	Schedule      string   `mapstructure:"Schedule" yaml:"Schedule"`
+	Duration      int64    `mapstructure:"Duration" yaml:"Duration"`
+	Calls         []string `mapstructure:"Calls" yaml:"Calls"`
+	OverlapPolicy string   `mapstructure:"OverlapPolicy" yaml:"OverlapPolicy"`
+}
+
+// EndpointConfig declares an extra endpoint of the router. Name is the span name and defaults to Path, Method
+// defaults to GET. ErrorRate is the fraction of requests, between 0 and 1, that fail with ErrorStatus (default 500).
+// ResponseSize pads the response body to at least that many bytes and Attributes are key=value span attributes.
+type EndpointConfig struct {
+	Name         string               `mapstructure:"Name" yaml:"Name"`
+	Path         string               `mapstructure:"Path" yaml:"Path"`
+	Method       string               `mapstructure:"Method" yaml:"Method"`
+	Latency      LatencyConfig        `mapstructure:"Latency" yaml:"Latency"`
+	Calls        []EndpointCallConfig `mapstructure:"Calls" yaml:"Calls"`
+	ErrorRate    float64              `mapstructure:"ErrorRate" yaml:"ErrorRate"`
+	ErrorStatus  int                  `mapstructure:"ErrorStatus" yaml:"ErrorStatus"`
+	ResponseSize int                  `mapstructure:"ResponseSize" yaml:"ResponseSize"`
+	Attributes   []string             `mapstructure:"Attributes" yaml:"Attributes"`
+}
+
+// LatencyConfig describes the distribution of the simulated work of an endpoint, in milliseconds. Distribution is
+// fixed (Mean), uniform (between Min and Max), normal (Mean and StdDev) or exponential (Mean). Samples are clamped
+// between Min and Max when Max is set.
+type LatencyConfig struct {
+	Distribution string `mapstructure:"Distribution" yaml:"Distribution"`
+	Mean         int64  `mapstructure:"Mean" yaml:"Mean"`
+	StdDev       int64  `mapstructure:"StdDev" yaml:"StdDev"`
+	Min          int64  `mapstructure:"Min" yaml:"Min"`
+	Max          int64  `mapstructure:"Max" yaml:"Max"`
+}
+
+// EndpointCallConfig is a downstream call of an endpoint: either a URL called with GET, or the port of a peer sample
+// app invoked like /outgoing-sampleapp does, over SampleAppProtocol.
+type EndpointCallConfig struct {
+	Url  string `mapstructure:"Url" yaml:"Url"`
+	Peer string `mapstructure:"Peer" yaml:"Peer"`
+}
 
+// XrayDaemonConfig configures the export of spans to the X-Ray daemon, used when TraceExporter is xray. Address
+// defaults to AWS_XRAY_DAEMON_ADDRESS, then to 127.0.0.1:2000. Spans are exported in batches of at most BatchSize
+// spans, at least every BatchTimeout milliseconds.
+type XrayDaemonConfig struct {
+	Address      string `mapstructure:"Address" yaml:"Address"`
+	BatchSize    int    `mapstructure:"BatchSize" yaml:"BatchSize"`
+	BatchTimeout int64  `mapstructure:"BatchTimeout" yaml:"BatchTimeout"`
+}
+
+// SigV4Config configures the OTLP/HTTP export signed with SigV4 to the AWS OTLP endpoints, used when TraceExporter
+// or LogExporter is sigv4. Credentials come from the default credentials chain and Region defaults to the region of
+// the default session. The endpoints default to the X-Ray and CloudWatch Logs OTLP endpoints of the region, whose
+// SigV4 services are xray and logs. Logs are sent to LogGroup and LogStream.
+type SigV4Config struct {
+	Region         string `mapstructure:"Region" yaml:"Region"`
+	TracesEndpoint string `mapstructure:"TracesEndpoint" yaml:"TracesEndpoint"`
+	TracesService  string `mapstructure:"TracesService" yaml:"TracesService"`
+	LogsEndpoint   string `mapstructure:"LogsEndpoint" yaml:"LogsEndpoint"`
+	LogsService    string `mapstructure:"LogsService" yaml:"LogsService"`
+	LogGroup       string `mapstructure:"LogGroup" yaml:"LogGroup"`
+	LogStream      string `mapstructure:"LogStream" yaml:"LogStream"`
+}
+
+// XrayAnnotationsConfig selects the span attributes X-Ray indexes as annotations, so traces can be filtered by them;
+// the other attributes are recorded as metadata. Keys are attributes already set on the spans, such as signal or
+// host. Static attributes are key=value pairs, FromHeaders are key=Header pairs read from the incoming request and
+// FromBaggage are keys of the incoming baggage. They are added to every span of the request.
+type XrayAnnotationsConfig struct {
+	Keys        []string `mapstructure:"Keys" yaml:"Keys"`
+	Static      []string `mapstructure:"Static" yaml:"Static"`
+	FromHeaders []string `mapstructure:"FromHeaders" yaml:"FromHeaders"`
+	FromBaggage []string `mapstructure:"FromBaggage" yaml:"FromBaggage"`
+}
+
+// ApplicationSignalsConfig enables the aws.* span attributes and the latency, error and fault metrics expected by
+// CloudWatch Application Signals. It needs an SDK tracer provider.
+type ApplicationSignalsConfig struct {
+	Enabled bool `mapstructure:"Enabled" yaml:"Enabled"`
+}
+
+// configOption ties a configuration key to its default value and command line flag.
+type configOption struct {
+	key          string
+	flag         string
+	defaultValue interface{}
+	usage        string
+}
+
+// configOptions lists every configuration key. The type of the default value decides the type of the flag.
+var configOptions = []configOption{
+	{"ServiceName", "service-name", "go-sample-app", "Service name of the resource, overridden by OTEL_SERVICE_NAME"},
+	{"InstanceId", "instance-id", "", "Instance ID, defaults to the INSTANCE_ID environment variable"},
+	{"Seed", "seed", int64(0), "Seed of the random values of every generator, 0 to seed from the clock"},
+	{"MetricNameSuffix", "metric-name-suffix", true, "Append _<instance ID> to every metric name, false adds the service.instance.id metric attribute instead"},
+	{"Host", "host", "0.0.0.0", "Host - String Address"},
+	{"Port", "port", "8080", "Port - String Port"},
+	{"TimeInterval", "time-interval", int64(1), "Interval - Time in seconds to generate new metrics"},
+	{"RandomTimeAliveIncrementer", "random-time-alive-incrementer", int64(1), "Metric - Amount to incremement metric by every TimeInterval"},
+	{"RandomTotalHeapSizeUpperBound", "random-total-heap-size-upper-bound", int64(100), "Metric - UpperBound for TotalHeapSize for random metric value every TimeInterval"},
+	{"RandomThreadsActiveUpperBound", "random-threads-active-upper-bound", int64(10), "Metric - UpperBound for ThreadsActive for random metric value every TimeInterval"},
+	{"RandomCpuUsageUpperBound", "random-cpu-usage-upper-bound", int64(100), "Metric - UpperBound for CpuUsage for random metric value every TimeInterval"},
+	{"SampleAppPorts", "sample-app-ports", []string{}, "Sampleapp ports to make calls to"},
+	{"SampleAppProtocol", "sample-app-protocol", protocolHttp, "Protocol used to call the sample app ports, http or grpc"},
+	{"GrpcPort", "grpc-port", "", "Port of the gRPC server, empty to disable it"},
+	{"DebugToken", "debug-token", "", "Bearer token required by /debug/telemetry, empty to disable the check"},
+	{"SyntheticMetrics.Count", "synthetic-metrics-count", 0, "Number of synthetic instruments per kind, 0 to disable"},
+	{"SyntheticMetrics.Kinds", "synthetic-metrics-kinds", SyntheticMetricKinds, "Kinds of synthetic instruments to create"},
+	{"SyntheticMetrics.AttributeCount", "synthetic-metrics-attribute-count", 1, "Number of attributes on every synthetic series"},
+	{"SyntheticMetrics.Cardinality", "synthetic-metrics-cardinality", 1, "Number of series per synthetic instrument"},
+	{"SyntheticMetrics.Interval", "synthetic-metrics-interval", int64(1), "Time in seconds between updates of the synchronous synthetic instruments"},
+	{"RequestMetricAttributes.Route", "request-metric-attributes-route", false, "Add the http.route attribute to the request based metrics"},
+	{"RequestMetricAttributes.Method", "request-metric-attributes-method", false, "Add the http.method attribute to the request based metrics"},
+	{"RequestMetricAttributes.StatusCode", "request-metric-attributes-status-code", false, "Add the http.status_code attribute to the request based metrics"},
+	{"Baggage.AllowList", "baggage-allow-list", []string{}, "Baggage keys added as span attributes, e.g. tenant,user.tier"},
+	{"Baggage.MetricAttributes", "baggage-metric-attributes", false, "Also add the allowed baggage entries to the request based metrics"},
+	{"CommonAttributes.All.Static", "common-attributes-all-static", []string{}, "Attributes added to every signal, e.g. team=payments,env=prod"},
+	{"CommonAttributes.All.FromEnv", "common-attributes-all-from-env", []string{}, "Attributes added to every signal from environment variables, e.g. region=AWS_REGION"},
+	{"CommonAttributes.Traces.Static", "common-attributes-traces-static", []string{}, "Attributes added to the spans"},
+	{"CommonAttributes.Traces.FromEnv", "common-attributes-traces-from-env", []string{}, "Attributes added to the spans from environment variables"},
+	{"CommonAttributes.Metrics.Static", "common-attributes-metrics-static", []string{}, "Attributes added to the metrics"},
+	{"CommonAttributes.Metrics.FromEnv", "common-attributes-metrics-from-env", []string{}, "Attributes added to the metrics from environment variables"},
+	{"Database.Enabled", "database-enabled", true, "Open the embedded database and register /db-call"},
+	{"Database.Rows", "database-rows", 100, "Number of rows seeded in the table of the embedded database"},
+	{"Database.SelectRows", "database-select-rows", 10, "Maximum number of rows read by every select query"},
+	{"Database.QueryMix", "database-query-mix", []string{"select", "select", "insert", "update"}, "Queries run by every /db-call request, in order, among select, insert, update and delete"},
+	{"Database.SanitizeStatements", "database-sanitize-statements", false, "Replace the literals of db.statement with placeholders"},
+	{"Messaging.Queue", "messaging-queue", queueMemory, "Queue used by /publish, memory or sqs"},
+	{"Messaging.QueueUrl", "messaging-queue-url", "", "URL of the SQS queue"},
+	{"Messaging.SqsEndpoint", "messaging-sqs-endpoint", "", "Endpoint of an SQS compatible service such as ElasticMQ, empty for AWS"},
+	{"Messaging.Capacity", "messaging-capacity", 1000, "Maximum number of messages waiting in the in-process queue"},
+	{"Messaging.Consumers", "messaging-consumers", 1, "Number of background consumers"},
+	{"Messaging.ProcessingTime", "messaging-processing-time", int64(10), "Time in milliseconds spent processing every message"},
+	{"TraceExporter", "trace-exporter", traceExporterOtlp, "Exporter of the spans, otlp, xray for the X-Ray daemon or sigv4 for the AWS OTLP endpoint"},
+	{"XrayDaemon.Address", "xray-daemon-address", "", "UDP address of the X-Ray daemon, defaults to AWS_XRAY_DAEMON_ADDRESS or 127.0.0.1:2000"},
+	{"XrayDaemon.BatchSize", "xray-daemon-batch-size", 512, "Maximum number of spans sent to the X-Ray daemon per batch"},
+	{"XrayDaemon.BatchTimeout", "xray-daemon-batch-timeout", int64(1000), "Maximum time in milliseconds before a batch is sent to the X-Ray daemon"},
+	{"LogExporter", "log-exporter", logExporterNone, "Exporter of the request logs, none or sigv4 for the AWS OTLP endpoint"},
+	{"SigV4.Region", "sigv4-region", "", "Region of the AWS OTLP endpoints, defaults to the region of the default session"},
+	{"SigV4.TracesEndpoint", "sigv4-traces-endpoint", "", "OTLP/HTTP traces endpoint, defaults to https://xray.<region>.amazonaws.com/v1/traces"},
+	{"SigV4.TracesService", "sigv4-traces-service", defaultTracesService, "SigV4 service of the traces endpoint"},
+	{"SigV4.LogsEndpoint", "sigv4-logs-endpoint", "", "OTLP/HTTP logs endpoint, defaults to https://logs.<region>.amazonaws.com/v1/logs"},
+	{"SigV4.LogsService", "sigv4-logs-service", defaultLogsService, "SigV4 service of the logs endpoint"},
+	{"SigV4.LogGroup", "sigv4-log-group", "", "CloudWatch log group of the request logs"},
+	{"SigV4.LogStream", "sigv4-log-stream", "", "CloudWatch log stream of the request logs"},
+	{"XrayAnnotations.Keys", "xray-annotations-keys", []string{}, "Span attributes indexed as X-Ray annotations, e.g. signal,host"},
+	{"XrayAnnotations.Static", "xray-annotations-static", []string{}, "Annotations added to every span, e.g. team=payments"},
+	{"XrayAnnotations.FromHeaders", "xray-annotations-from-headers", []string{}, "Annotations read from request headers, e.g. tenant=X-Tenant-Id"},
+	{"XrayAnnotations.FromBaggage", "xray-annotations-from-baggage", []string{}, "Baggage keys added as annotations, e.g. tenant"},
+	{"ApplicationSignals.Enabled", "application-signals-enabled", false, "Add the Application Signals span attributes and metrics"},
+	{"SemconvStability", "semconv-stability", "", "HTTP semantic conventions of the spans, metrics and request logs, old, stable or dup for both, defaults to OTEL_SEMCONV_STABILITY_OPT_IN"},
+	{"TraceResponseHeaders", "trace-response-headers", true, "Return the trace context in the traceresponse, X-Amzn-Trace-Id and Server-Timing response headers"},
+	{"ResponseTraceIdFormat", "response-trace-id-format", traceIdFormatXray, "Format of the trace ID in the response body, xray or w3c"},
+}
+
+// RegisterFlags adds a flag for every configuration key to fs.
+func RegisterFlags(fs *pflag.FlagSet) {
+	for _, opt := range configOptions {
+		switch v := opt.defaultValue.(type) {
+		case string:
+			fs.String(opt.flag, v, opt.usage)
+		case int:
+			fs.Int(opt.flag, v, opt.usage)
+		case int64:
+			fs.Int64(opt.flag, v, opt.usage)
+		case bool:
+			fs.Bool(opt.flag, v, opt.usage)
+		case []string:
+			fs.StringSlice(opt.flag, v, opt.usage)
+		default:
+			panic(fmt.Sprintf("unsupported type %T for configuration key %s", v, opt.key))
+		}
+	}
+}
+
+// ConfigFile returns the configuration file set by the SAMPLE_APP_CONF environment variable, or config.yaml.
+func ConfigFile() string {
+	if file, present := os.LookupEnv("SAMPLE_APP_CONF"); present {
+		return file
+	}
+	return DefaultConfigFile
+}
+
+// LoadConfiguration returns a configured Config struct with the precedence;
+// Default Values < Configuration File < Environment Variables < Flags.
+// A missing file is only an error when it is not the default configuration file and no file is read when file is
+// empty. fs may be nil, only the flags that were set on the command line override other values.
+func LoadConfiguration(file string, fs *pflag.FlagSet) (*Config, error) {
+	v := viper.New()
+	for _, opt := range configOptions {
+		v.SetDefault(opt.key, opt.defaultValue)
+		if fs != nil {
+			if f := fs.Lookup(opt.flag); f != nil {
+				if err := v.BindPFlag(opt.key, f); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	if file != "" {
+		v.SetConfigFile(file)
+		if err := v.ReadInConfig(); err != nil {
+			if !(errors.Is(err, os.ErrNotExist) && file == DefaultConfigFile) {
+				return nil, fmt.Errorf("reading configuration file %s: %w", file, err)
+			}
+		}
+	}
+
+	// Values of the wrong type are removed and decoding is retried, so that they leave their setting empty and are
+	// reported by Validate together with the unknown keys and the other problems.
+	settings := v.AllSettings()
+	var decodeErrors []string
+	for {
+		cfg := &Config{}
+		md := &mapstructure.Metadata{}
+		err := decodeSettings(settings, cfg, md)
+		if err == nil {
+			cfg.unknownKeys = md.Unused
+			sort.Strings(cfg.unknownKeys)
+			cfg.decodeErrors = decodeErrors
+			return cfg, nil
+		}
+		var decodeErr *mapstructure.Error
+		if !errors.As(err, &decodeErr) {
+			return nil, fmt.Errorf("decoding configuration: %w", err)
+		}
+		removed := false
+		for _, e := range decodeErr.Errors {
+			decodeErrors = append(decodeErrors, e)
+			if key := decodeErrorKey(e); key != "" && removeSetting(settings, key) {
+				removed = true
+			}
+		}
+		if !removed {
+			cfg.decodeErrors = decodeErrors
+			return cfg, nil
+		}
+	}
+}
+
+// decodeSettings decodes the settings of viper into cfg like viper.Unmarshal, recording the keys in md.
+func decodeSettings(settings map[string]interface{}, cfg *Config, md *mapstructure.Metadata) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Metadata:         md,
+		Result:           cfg,
+		WeaklyTypedInput: true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(settings)
+}
+
+// decodeErrorKey returns the setting named by a mapstructure decode error, e.g. Jobs[0].Duration.
+func decodeErrorKey(err string) string {
+	if m := decodeErrorKeyPattern.FindStringSubmatch(err); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+var decodeErrorKeyPattern = regexp.MustCompile(`'([^']*)'`)
+
+// removeSetting removes the setting at key, e.g. Jobs[0].Duration, from the settings of viper, whose keys are lower
+// case. A failing element of a list removes the whole list. It reports whether the setting was found.
+func removeSetting(settings map[string]interface{}, key string) bool {
+	current := settings
+	parts := strings.Split(strings.ToLower(key), ".")
+	for i, part := range parts {
+		name, index := part, -1
+		if open := strings.IndexByte(part, '['); open >= 0 && strings.HasSuffix(part, "]") {
+			name = part[:open]
+			if n, err := strconv.Atoi(part[open+1 : len(part)-1]); err == nil {
+				index = n
+			}
+		}
+		value, ok := current[name]
+		if !ok {
+			return false
+		}
+		last := i == len(parts)-1
+		if index >= 0 {
+			list, ok := value.([]interface{})
+			if last || !ok || index >= len(list) {
+				delete(current, name)
+				return true
+			}
+			value = list[index]
+		} else if last {
+			delete(current, name)
+			return true
+		}
+		next, ok := value.(map[string]interface{})
+		if !ok {
+			delete(current, name)
+			return true
+		}
+		current = next
+	}
+	return false
+}
+
+// GetConfiguration returns a configured Config struct with the precedence;
+// Default Values < Configuration File < Environment Variables. When the configuration cannot be loaded the error is
+// printed and the default values are used instead.
+func GetConfiguration() *Config {
+	cfg, err := LoadConfiguration(ConfigFile(), nil)
+	if err != nil {
+		fmt.Println(err)
+		cfg, _ = LoadConfiguration("", nil)
+	}
 	return cfg
 }
@@ -0,0 +1,341 @@
+package collection
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"modernc.org/sqlite"
+)
+
+// Contains the embedded database used by the /db-call endpoint and its database/sql instrumentation.
+
+const dbName = "sampleapp"
+const dbTable = "items"
+
+// DbQueryKinds lists the queries the /db-call endpoint can run.
+var DbQueryKinds = []string{"select", "insert", "update", "delete"}
+
+// sqlLiteral matches the string and numeric literals of a statement.
+var sqlLiteral = regexp.MustCompile(`'(?:[^']|'')*'|\b\d+(?:\.\d+)?\b`)
+
+// sanitizeStatement replaces the literals of statement with placeholders.
+func sanitizeStatement(statement string) string {
+	return sqlLiteral.ReplaceAllString(statement, "?")
+}
+
+// sqlTable matches the table a statement reads or writes.
+var sqlTable = regexp.MustCompile(`(?i)\b(?:FROM|INTO|UPDATE|TABLE)\s+(\w+)`)
+
+// database is an in-memory sqlite database. Its connections are wrapped by tracedConn, so every statement is traced as
+// a CLIENT span with the db.* semantic attributes and recorded in a duration histogram.
+type database struct {
+	db       *sql.DB
+	cfg      DatabaseConfig
+	tracer   trace.Tracer
+	labels   func(...attribute.KeyValue) []attribute.KeyValue
+	rand     *rand.Rand
+	duration instrument.Float64Histogram
+}
+
+// openDatabase opens the embedded database and seeds its table with cfg.Rows rows. nameSuffix is appended to the
+// metric name and labels returns its attributes. rnd generates the values of the rows and statements.
+func openDatabase(ctx context.Context, tracer trace.Tracer, mp metric.MeterProvider, cfg DatabaseConfig, nameSuffix string,
+	labels func(...attribute.KeyValue) []attribute.KeyValue, rnd *rand.Rand) (*database, error) {
+	duration, err := mp.Meter(instrumentationName).Float64Histogram(
+		dbQueryDuration+nameSuffix,
+		instrument.WithDescription("Measures the duration of the queries run against the embedded database"),
+		instrument.WithUnit("ms"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &database{cfg: cfg, tracer: tracer, labels: labels, rand: rnd, duration: duration}
+	d.db = sql.OpenDB(tracedConnector{drv: &sqlite.Driver{}, dsn: "file::memory:", d: d})
+	// Every connection to :memory: opens a separate database
+	d.db.SetMaxOpenConns(1)
+
+	if _, err := d.db.ExecContext(ctx, "CREATE TABLE "+dbTable+" (id INTEGER PRIMARY KEY, name TEXT NOT NULL, price INTEGER NOT NULL)"); err != nil {
+		d.db.Close()
+		return nil, err
+	}
+	if cfg.Rows > 0 {
+		values := make([]string, cfg.Rows)
+		for i := range values {
+			values[i] = fmt.Sprintf("('item-%d', %d)", i, rnd.Intn(1000))
+		}
+		if _, err := d.db.ExecContext(ctx, "INSERT INTO "+dbTable+" (name, price) VALUES "+strings.Join(values, ", ")); err != nil {
+			d.db.Close()
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// statement returns a statement of the given kind. Values are inlined as literals, like many real applications do,
+// so that sanitization has something to remove.
+func (d *database) statement(kind string) (string, error) {
+	rows := d.cfg.Rows
+	if rows < 1 {
+		rows = 1
+	}
+	switch kind {
+	case "select":
+		return fmt.Sprintf("SELECT id, name, price FROM %s WHERE price > %d ORDER BY price DESC LIMIT %d", dbTable, d.rand.Intn(500), d.cfg.SelectRows), nil
+	case "insert":
+		n := d.rand.Intn(1000000)
+		return fmt.Sprintf("INSERT INTO %s (name, price) VALUES ('item-%d', %d)", dbTable, n, d.rand.Intn(1000)), nil
+	case "update":
+		return fmt.Sprintf("UPDATE %s SET price = %d WHERE id = %d", dbTable, d.rand.Intn(1000), d.rand.Intn(rows)+1), nil
+	case "delete":
+		return fmt.Sprintf("DELETE FROM %s WHERE id = %d", dbTable, d.rand.Intn(rows)+1), nil
+	}
+	return "", fmt.Errorf("unknown query kind %q, expected one of %v", kind, DbQueryKinds)
+}
+
+// run runs statement and returns the number of rows read or affected. The statement is traced by the connection.
+func (d *database) run(ctx context.Context, statement string) (n int64, err error) {
+	if !strings.EqualFold(strings.Fields(statement)[0], "SELECT") {
+		res, err := d.db.ExecContext(ctx, statement)
+		if err != nil {
+			return 0, err
+		}
+		return res.RowsAffected()
+	}
+	rows, err := d.db.QueryContext(ctx, statement)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id, price int64
+			name      string
+		)
+		if err := rows.Scan(&id, &name, &price); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, rows.Err()
+}
+
+// startQuery starts the CLIENT span of query and returns a function that records its duration and ends the span.
+func (d *database) startQuery(ctx context.Context, query string) (context.Context, func(error)) {
+	var operation string
+	if fields := strings.Fields(query); len(fields) > 0 {
+		operation = strings.ToUpper(fields[0])
+	}
+	attrs := []attribute.KeyValue{semconv.DBSystemSqlite, semconv.DBOperation(operation)}
+	name := operation + " " + dbName
+	if m := sqlTable.FindStringSubmatch(query); m != nil {
+		attrs = append(attrs, semconv.DBSQLTable(m[1]))
+		name += "." + m[1]
+	}
+
+	recorded := query
+	if d.cfg.SanitizeStatements {
+		recorded = sanitizeStatement(query)
+	}
+	ctx, span := d.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(semconv.DBName(dbName), semconv.DBStatement(recorded)),
+	)
+	start := time.Now()
+	return ctx, func(err error) {
+		d.duration.Record(ctx, float64(time.Since(start).Microseconds())/1000, d.labels(attrs...)...)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// tracedConnector opens connections of drv to dsn whose statements are traced by d.
+type tracedConnector struct {
+	drv driver.Driver
+	dsn string
+	d   *database
+}
+
+func (c tracedConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.drv.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return tracedConn{Conn: conn, d: c.d}, nil
+}
+
+func (c tracedConnector) Driver() driver.Driver {
+	return c.drv
+}
+
+// tracedConn traces the statements run on a driver connection, whether they are executed directly or prepared.
+type tracedConn struct {
+	driver.Conn
+	d *database
+}
+
+func (c tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, end := c.d.startQuery(ctx, query)
+	res, err := execer.ExecContext(ctx, query, args)
+	end(err)
+	return res, err
+}
+
+func (c tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, end := c.d.startQuery(ctx, query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		end(err)
+		return nil, err
+	}
+	return tracedRows{Rows: rows, end: end}, nil
+}
+
+func (c tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tracedStmt{Stmt: stmt, query: query, d: c.d}, nil
+}
+
+func (c tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+// tracedStmt traces the executions of a prepared statement.
+type tracedStmt struct {
+	driver.Stmt
+	query string
+	d     *database
+}
+
+func (s tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	ctx, end := s.d.startQuery(ctx, s.query)
+	var (
+		res driver.Result
+		err error
+	)
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = execer.ExecContext(ctx, args)
+	} else {
+		res, err = s.Stmt.Exec(namedValues(args))
+	}
+	end(err)
+	return res, err
+}
+
+func (s tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	ctx, end := s.d.startQuery(ctx, s.query)
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(namedValues(args))
+	}
+	if err != nil {
+		end(err)
+		return nil, err
+	}
+	return tracedRows{Rows: rows, end: end}, nil
+}
+
+// namedValues returns the values of args for the context-less driver methods.
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
+
+// tracedRows ends the span of a query once its rows are closed, so that reading them is part of the query.
+type tracedRows struct {
+	driver.Rows
+	end func(error)
+}
+
+func (r tracedRows) Close() error {
+	err := r.Rows.Close()
+	r.end(err)
+	return err
+}
+
+// Close closes the database.
+func (d *database) Close() error {
+	return d.db.Close()
+}
+
+// DbCall runs the configured query mix against the embedded database and generates an Xray Trace ID. Every query is
+// traced as a CLIENT span with the db.* semantic attributes. It is only registered when Database.Enabled is set.
+func (a *App) DbCall(w http.ResponseWriter, r *http.Request) {
+	resp, err := a.dbCall(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	a.writeResponse(w, resp)
+}
+
+// dbCall is the operation behind DbCall, shared by the HTTP and gRPC servers.
+func (a *App) dbCall(ctx context.Context) (response, error) {
+	ctx, span := a.tracer.Start(
+		ctx,
+		"db-call",
+		trace.WithAttributes(a.traceLabels...),
+	)
+	defer span.End()
+
+	for _, kind := range a.cfg.Database.QueryMix {
+		statement, err := a.db.statement(kind)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return response{}, err
+		}
+		if _, err := a.db.run(ctx, statement); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return response{}, err
+		}
+	}
+	return a.newResponse(span, nil), nil
+}
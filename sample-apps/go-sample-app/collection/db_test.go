@@ -0,0 +1,102 @@
+package collection
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// clientSpans returns the CLIENT spans recorded by ta, by name.
+func clientSpans(ta *testApp) map[string]tracetest.SpanStub {
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range ta.spans.GetSpans() {
+		if span.SpanKind == trace.SpanKindClient {
+			spans[span.Name] = span
+		}
+	}
+	return spans
+}
+
+func TestDatabase(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Database.QueryMix = []string{"select", "insert"}
+	cfg.Database.SanitizeStatements = true
+	ta := newTestApp(t, cfg)
+
+	t.Run("db-call", func(t *testing.T) {
+		ta.spans.Reset()
+		if rec := ta.serve(t, http.MethodGet, "/db-call"); rec.Code != http.StatusOK {
+			t.Fatalf("/db-call returned %d", rec.Code)
+		}
+
+		spans := clientSpans(ta)
+		for name, statement := range map[string]string{
+			"SELECT sampleapp.items": "SELECT id, name, price FROM items WHERE price > ? ORDER BY price DESC LIMIT ?",
+			"INSERT sampleapp.items": "INSERT INTO items (name, price) VALUES (?, ?)",
+		} {
+			span, ok := spans[name]
+			if !ok {
+				t.Errorf("no %s span in %v", name, spans)
+				continue
+			}
+			if got := spanAttr(span, semconv.DBSystemKey).AsString(); got != "sqlite" {
+				t.Errorf("%s: db.system = %q, want sqlite", name, got)
+			}
+			if got := spanAttr(span, semconv.DBSQLTableKey).AsString(); got != dbTable {
+				t.Errorf("%s: db.sql.table = %q, want %s", name, got, dbTable)
+			}
+			if got := spanAttr(span, semconv.DBStatementKey).AsString(); got != statement {
+				t.Errorf("%s: db.statement = %q, want %q", name, got, statement)
+			}
+		}
+	})
+
+	t.Run("direct query", func(t *testing.T) {
+		ta.spans.Reset()
+		var count int
+		if err := ta.db.db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM items WHERE price >= 0").Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+
+		span, ok := clientSpans(ta)["SELECT sampleapp.items"]
+		if !ok {
+			t.Fatal("a query run outside of /db-call was not traced")
+		}
+		if got, want := spanAttr(span, semconv.DBStatementKey).AsString(), "SELECT COUNT(*) FROM items WHERE price >= ?"; got != want {
+			t.Errorf("db.statement = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("duration", func(t *testing.T) {
+		metrics := ta.metrics(t)
+		dims := func(operation string) []attribute.KeyValue {
+			return ta.rqmc.Labels(semconv.DBSystemSqlite, semconv.DBOperation(operation), semconv.DBSQLTable(dbTable))
+		}
+		// The select of /db-call and the direct query
+		if dp, ok := histogramPoint(t, metrics[dbQueryDuration], dims("SELECT")...); !ok || dp.Count != 2 {
+			t.Errorf("recorded %d select durations, want 2", dp.Count)
+		}
+		// The seeding and /db-call inserts
+		if dp, ok := histogramPoint(t, metrics[dbQueryDuration], dims("INSERT")...); !ok || dp.Count != 2 {
+			t.Errorf("recorded %d insert durations, want 2", dp.Count)
+		}
+	})
+}
+
+func TestDatabaseDisabled(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Database.Enabled = false
+	ta := newTestApp(t, cfg)
+
+	if ta.db != nil {
+		t.Error("the database was opened")
+	}
+	if rec := ta.serve(t, http.MethodGet, "/db-call"); rec.Code != http.StatusNotFound {
+		t.Errorf("/db-call returned %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
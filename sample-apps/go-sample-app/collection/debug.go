@@ -0,0 +1,200 @@
+package collection
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Contains the introspection endpoint logic.
+
+// countingSpanProcessor counts the spans started and ended by the trace provider.
+type countingSpanProcessor struct {
+	started int64
+	ended   int64
+}
+
+func (p *countingSpanProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {
+	atomic.AddInt64(&p.started, 1)
+}
+
+func (p *countingSpanProcessor) OnEnd(sdktrace.ReadOnlySpan) {
+	atomic.AddInt64(&p.ended, 1)
+}
+
+func (p *countingSpanProcessor) Shutdown(context.Context) error { return nil }
+
+func (p *countingSpanProcessor) ForceFlush(context.Context) error { return nil }
+
+type telemetryResponse struct {
+	Config      Config                    `json:"config"`
+	Resource    map[string]interface{}    `json:"resource"`
+	Instruments []instrumentState         `json:"instruments"`
+	Spans       spanCounters              `json:"spans"`
+	Exports     map[string]exportCounters `json:"exports"`
+	Errors      []string                  `json:"errors,omitempty"`
+}
+
+type instrumentState struct {
+	Scope       string           `json:"scope"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Unit        string           `json:"unit"`
+	Kind        string           `json:"kind"`
+	DataPoints  []dataPointState `json:"dataPoints"`
+}
+
+type dataPointState struct {
+	Attributes   map[string]interface{} `json:"attributes"`
+	Value        interface{}            `json:"value,omitempty"`
+	Count        uint64                 `json:"count,omitempty"`
+	Sum          float64                `json:"sum,omitempty"`
+	Bounds       []float64              `json:"bounds,omitempty"`
+	BucketCounts []uint64               `json:"bucketCounts,omitempty"`
+}
+
+type spanCounters struct {
+	Started int64 `json:"started"`
+	Ended   int64 `json:"ended"`
+}
+
+type exportCounters struct {
+	Exports  int64  `json:"exports"`
+	Failures int64  `json:"failures"`
+	Items    int64  `json:"items"`
+	LastErr  string `json:"lastError,omitempty"`
+}
+
+// DebugTelemetry returns the effective configuration, the resource, the current value of every registered
+// instrument and the span and export counters. When DebugToken is configured the request must carry it
+// as a bearer token.
+func (a *App) DebugTelemetry(w http.ResponseWriter, r *http.Request) {
+	if a.cfg.DebugToken != "" && !validDebugToken(r, a.cfg.DebugToken) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	resp := telemetryResponse{
+		Config:   *a.cfg,
+		Resource: attributesToMap(a.resource.Attributes()),
+		Spans: spanCounters{
+			Started: atomic.LoadInt64(&a.spanCounter.started),
+			Ended:   atomic.LoadInt64(&a.spanCounter.ended),
+		},
+		Exports: map[string]exportCounters{
+			"traces":  a.traceStatus.counters(),
+			"metrics": a.metricStatus.counters(),
+		},
+	}
+	if a.logs != nil {
+		resp.Exports["logs"] = a.logStatus.counters()
+	}
+	resp.Config.DebugToken = ""
+
+	// Instruments can only be read back when the App started its own meter provider
+	rm := metricdata.ResourceMetrics{}
+	if a.debugReader == nil {
+		resp.Errors = append(resp.Errors, "meter provider was supplied externally, instruments are not available")
+	} else if err := a.debugReader.Collect(r.Context(), &rm); err != nil {
+		resp.Errors = append(resp.Errors, err.Error())
+	}
+	resp.Instruments = instrumentStates(rm)
+
+	payload, _ := json.Marshal(resp)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+// validDebugToken checks the bearer token of the request in constant time.
+func validDebugToken(r *http.Request, token string) bool {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	got := strings.TrimPrefix(header, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// counters returns the export totals of a signal.
+func (es *exportStatus) counters() exportCounters {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	c := exportCounters{Exports: es.exports, Failures: es.failures, Items: es.items}
+	if es.lastErr != nil {
+		c.LastErr = es.lastErr.Error()
+	}
+	return c
+}
+
+// instrumentStates flattens collected metrics into their latest aggregated values.
+func instrumentStates(rm metricdata.ResourceMetrics) []instrumentState {
+	states := []instrumentState{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			st := instrumentState{
+				Scope:       sm.Scope.Name,
+				Name:        m.Name,
+				Description: m.Description,
+				Unit:        m.Unit,
+			}
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				st.Kind = sumKind(data.IsMonotonic)
+				for _, dp := range data.DataPoints {
+					st.DataPoints = append(st.DataPoints, dataPointState{Attributes: attributesToMap(dp.Attributes.ToSlice()), Value: dp.Value})
+				}
+			case metricdata.Sum[float64]:
+				st.Kind = sumKind(data.IsMonotonic)
+				for _, dp := range data.DataPoints {
+					st.DataPoints = append(st.DataPoints, dataPointState{Attributes: attributesToMap(dp.Attributes.ToSlice()), Value: dp.Value})
+				}
+			case metricdata.Gauge[int64]:
+				st.Kind = "gauge"
+				for _, dp := range data.DataPoints {
+					st.DataPoints = append(st.DataPoints, dataPointState{Attributes: attributesToMap(dp.Attributes.ToSlice()), Value: dp.Value})
+				}
+			case metricdata.Gauge[float64]:
+				st.Kind = "gauge"
+				for _, dp := range data.DataPoints {
+					st.DataPoints = append(st.DataPoints, dataPointState{Attributes: attributesToMap(dp.Attributes.ToSlice()), Value: dp.Value})
+				}
+			case metricdata.Histogram:
+				st.Kind = "histogram"
+				for _, dp := range data.DataPoints {
+					st.DataPoints = append(st.DataPoints, dataPointState{
+						Attributes:   attributesToMap(dp.Attributes.ToSlice()),
+						Count:        dp.Count,
+						Sum:          dp.Sum,
+						Bounds:       dp.Bounds,
+						BucketCounts: dp.BucketCounts,
+					})
+				}
+			}
+			states = append(states, st)
+		}
+	}
+	return states
+}
+
+func sumKind(monotonic bool) string {
+	if monotonic {
+		return "counter"
+	}
+	return "updowncounter"
+}
+
+func attributesToMap(attrs []attribute.KeyValue) map[string]interface{} {
+	m := make(map[string]interface{}, len(attrs))
+	for _, kv := range attrs {
+		m[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	return m
+}
@@ -0,0 +1,59 @@
+package collection
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDebugTelemetryToken(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.DebugToken = "s3cret"
+	ta := newTestApp(t, cfg)
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"missing token", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer guess", http.StatusUnauthorized},
+		{"token without bearer scheme", "s3cret", http.StatusUnauthorized},
+		{"valid token", "Bearer s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/debug/telemetry", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			ta.Handler().ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("/debug/telemetry returned %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized {
+				if got := rec.Header().Get("WWW-Authenticate"); got != "Bearer" {
+					t.Errorf("WWW-Authenticate = %q, want Bearer", got)
+				}
+				return
+			}
+			var resp telemetryResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Config.DebugToken != "" {
+				t.Error("the response discloses the debug token")
+			}
+		})
+	}
+}
+
+func TestDebugTelemetryWithoutToken(t *testing.T) {
+	ta := newTestApp(t, testConfig(t))
+	if rec := ta.serve(t, http.MethodGet, "/debug/telemetry"); rec.Code != http.StatusOK {
+		t.Errorf("/debug/telemetry returned %d without a configured token, want %d", rec.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,114 @@
+package collection
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Contains the custom endpoints declared in the configuration.
+
+// Latency distributions of the custom endpoints.
+const (
+	latencyFixed       = "fixed"
+	latencyUniform     = "uniform"
+	latencyNormal      = "normal"
+	latencyExponential = "exponential"
+)
+
+// builtinPaths are the paths served by the sample app itself, which custom endpoints cannot use.
+var builtinPaths = []string{
+	"/", "/aws-sdk-call", "/outgoing-http-call", "/outgoing-sampleapp", "/synthetic-trace", "/db-call", "/publish",
+	"/healthz", "/readyz", "/debug/telemetry",
+}
+
+// endpoint is a custom endpoint declared in the configuration.
+type endpoint struct {
+	cfg   EndpointConfig
+	attrs []attribute.KeyValue
+}
+
+// registerEndpoints registers the custom endpoints on the router, behind the same middlewares as the built-in ones.
+func (a *App) registerEndpoints() {
+	for _, cfg := range a.cfg.Endpoints {
+		if cfg.Name == "" {
+			cfg.Name = cfg.Path
+		}
+		if cfg.Method == "" {
+			cfg.Method = http.MethodGet
+		}
+		if cfg.ErrorStatus == 0 {
+			cfg.ErrorStatus = http.StatusInternalServerError
+		}
+		e := &endpoint{cfg: cfg, attrs: keyValueAttributes(cfg.Attributes)}
+		a.router.HandleFunc(cfg.Path, func(w http.ResponseWriter, r *http.Request) {
+			status, resp := a.serveEndpoint(r.Context(), e)
+			a.writeResponseStatus(w, status, resp)
+		}).Methods(strings.ToUpper(cfg.Method))
+	}
+}
+
+// serveEndpoint runs e in its own span: it simulates work for a latency drawn from the configured distribution, makes
+// the downstream calls in order and fails with the configured error rate. It returns the status code and the body.
+func (a *App) serveEndpoint(ctx context.Context, e *endpoint) (int, response) {
+	ctx, span := a.tracer.Start(
+		ctx,
+		e.cfg.Name,
+		trace.WithAttributes(a.traceLabels...),
+		trace.WithAttributes(e.attrs...),
+	)
+	defer span.End()
+
+	// Simulated work
+	time.Sleep(a.sampleLatency(e.cfg.Latency))
+
+	var downstream []downstreamResult
+	for _, call := range e.cfg.Calls {
+		if call.Peer != "" {
+			downstream = append(downstream, a.invoke(ctx, call.Peer))
+		} else {
+			downstream = append(downstream, a.call(ctx, call.Url))
+		}
+	}
+
+	status := http.StatusOK
+	if e.cfg.ErrorRate > 0 && a.rand.Float64() < e.cfg.ErrorRate {
+		status = e.cfg.ErrorStatus
+		err := fmt.Errorf("injected error on %s %s", e.cfg.Method, e.cfg.Path)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	resp := a.newResponse(span, downstream)
+	if e.cfg.ResponseSize > 0 {
+		resp.Padding = strings.Repeat("x", e.cfg.ResponseSize)
+	}
+	return status, resp
+}
+
+// sampleLatency draws a latency from the distribution described by cfg.
+func (a *App) sampleLatency(cfg LatencyConfig) time.Duration {
+	var ms float64
+	switch cfg.Distribution {
+	case latencyUniform:
+		ms = float64(cfg.Min) + a.rand.Float64()*float64(cfg.Max-cfg.Min)
+	case latencyNormal:
+		ms = float64(cfg.Mean) + a.rand.NormFloat64()*float64(cfg.StdDev)
+	case latencyExponential:
+		ms = a.rand.ExpFloat64() * float64(cfg.Mean)
+	default:
+		ms = float64(cfg.Mean)
+	}
+	if cfg.Max > 0 {
+		ms = math.Min(ms, float64(cfg.Max))
+	}
+	ms = math.Max(ms, float64(cfg.Min))
+	return time.Duration(ms * float64(time.Millisecond))
+}
@@ -0,0 +1,39 @@
+package collection
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSampleLatency(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      LatencyConfig
+		min, max float64 // bounds of every sample, in milliseconds
+		mean     float64 // expected mean of the samples, in milliseconds
+	}{
+		{"fixed", LatencyConfig{Distribution: latencyFixed, Mean: 40}, 40, 40, 40},
+		{"uniform", LatencyConfig{Distribution: latencyUniform, Min: 10, Max: 30}, 10, 30, 20},
+		{"normal", LatencyConfig{Distribution: latencyNormal, Mean: 100, StdDev: 10}, 0, math.Inf(1), 100},
+		{"exponential", LatencyConfig{Distribution: latencyExponential, Mean: 50}, 0, math.Inf(1), 50},
+		{"clamped", LatencyConfig{Distribution: latencyNormal, Mean: 100, StdDev: 50, Min: 90, Max: 110}, 90, 110, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &App{rand: newRand(1)}
+			const n = 10000
+			var sum float64
+			for i := 0; i < n; i++ {
+				ms := float64(a.sampleLatency(tt.cfg)) / float64(time.Millisecond)
+				if ms < tt.min || ms > tt.max {
+					t.Fatalf("sample %d = %.2fms, want between %.0f and %.0f", i, ms, tt.min, tt.max)
+				}
+				sum += ms
+			}
+			if mean := sum / n; math.Abs(mean-tt.mean) > tt.mean*0.05 {
+				t.Errorf("mean = %.2fms, want %.0f within 5%%", mean, tt.mean)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package collection
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Contains the gRPC server exposing the same operations as the router endpoints, and the gRPC client used to invoke
+// peer sample apps.
+
+// grpcServiceName is the fully qualified name of the sample app gRPC service. Requests and responses are
+// google.protobuf.Struct messages holding the same fields as the query parameters and the JSON body of the router
+// endpoints, so no generated code is needed on either side.
+const grpcServiceName = "sampleapp.SampleApp"
+
+// Protocols used to invoke the peer sample apps.
+const (
+	protocolHttp = "http"
+	protocolGrpc = "grpc"
+)
+
+// grpcOperation serves one method of the gRPC service.
+type grpcOperation func(ctx context.Context, req *structpb.Struct) (response, error)
+
+// GRPCServer returns a gRPC server exposing the sample app operations as the sampleapp.SampleApp service. Every call
+// is traced by otelgrpc and recorded in the request based metrics. The caller serves and stops the server.
+func (a *App) GRPCServer() *grpc.Server {
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		otelgrpc.UnaryServerInterceptor(
+			otelgrpc.WithTracerProvider(a.tp),
+			otelgrpc.WithMeterProvider(a.mp),
+			otelgrpc.WithPropagators(a.propagator),
+		),
+		a.grpcMetricsInterceptor,
+	))
+
+	operations := []struct {
+		name string
+		op   grpcOperation
+	}{
+		{"AwsSdkCall", func(ctx context.Context, _ *structpb.Struct) (response, error) {
+			return a.awsSdkCall(ctx), nil
+		}},
+		{"OutgoingHttpCall", func(ctx context.Context, _ *structpb.Struct) (response, error) {
+			return a.outgoingHttpCall(ctx), nil
+		}},
+		{"OutgoingSampleApp", func(ctx context.Context, _ *structpb.Struct) (response, error) {
+			return a.outgoingSampleApp(ctx), nil
+		}},
+		{"DbCall", func(ctx context.Context, _ *structpb.Struct) (response, error) {
+			if a.db == nil {
+				return response{}, status.Error(codes.Unimplemented, "the database is disabled")
+			}
+			resp, err := a.dbCall(ctx)
+			if err != nil {
+				return response{}, status.Error(codes.Internal, err.Error())
+			}
+			return resp, nil
+		}},
+		{"Publish", func(ctx context.Context, req *structpb.Struct) (response, error) {
+			count := 1
+			if v, ok := req.GetFields()["count"]; ok {
+				count = int(v.GetNumberValue())
+			}
+			if count < 1 || count > maxPublishCount {
+				return response{}, status.Errorf(codes.InvalidArgument, "count must be between 1 and %d", maxPublishCount)
+			}
+			resp, err := a.publish(ctx, count)
+			if err != nil {
+				return response{}, status.Error(codes.Unavailable, err.Error())
+			}
+			return resp, nil
+		}},
+		{"SyntheticTrace", func(ctx context.Context, req *structpb.Struct) (response, error) {
+			q := url.Values{}
+			for k, v := range req.AsMap() {
+				q.Set(k, fmt.Sprint(v))
+			}
+			p, err := parseSyntheticTraceParams(q)
+			if err != nil {
+				return response{}, status.Error(codes.InvalidArgument, err.Error())
+			}
+			return a.syntheticTrace(ctx, p), nil
+		}},
+	}
+
+	desc := grpc.ServiceDesc{
+		ServiceName: grpcServiceName,
+		HandlerType: (*interface{})(nil),
+	}
+	for _, o := range operations {
+		desc.Methods = append(desc.Methods, grpcMethod(o.name, o.op))
+	}
+	srv.RegisterService(&desc, a)
+	return srv
+}
+
+// GRPCAddr returns the address the gRPC server is configured to listen on, or an empty string when it is disabled.
+func (a *App) GRPCAddr() string {
+	if a.cfg.GrpcPort == "" {
+		return ""
+	}
+	return net.JoinHostPort(a.cfg.Host, a.cfg.GrpcPort)
+}
+
+// grpcMethod adapts op to a unary method of the sample app service.
+func grpcMethod(name string, op grpcOperation) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := &structpb.Struct{}
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				resp, err := op(ctx, req.(*structpb.Struct))
+				if err != nil {
+					return nil, err
+				}
+				return responseToStruct(resp)
+			}
+			if interceptor == nil {
+				return handler(ctx, in)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + grpcServiceName + "/" + name}
+			return interceptor(ctx, in, info, handler)
+		},
+	}
+}
+
+// grpcMetricsInterceptor records the request based metrics for every gRPC call, with the size of the response message
+// as the bytes sent. The rpc.system, rpc.service and rpc.method attributes are always added so gRPC requests can be
+// told apart from HTTP requests.
+func (a *App) grpcMetricsInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	latency := time.Since(start)
+
+	service, method := splitFullMethod(info.FullMethod)
+	attrs := []attribute.KeyValue{semconv.RPCSystemGRPC, semconv.RPCService(service), semconv.RPCMethod(method)}
+	if a.cfg.RequestMetricAttributes.StatusCode {
+		attrs = append(attrs, semconv.RPCGRPCStatusCodeKey.Int(int(status.Code(err))))
+	}
+	if a.cfg.Baggage.MetricAttributes {
+		attrs = append(attrs, baggageAttributes(ctx, a.cfg.Baggage.AllowList)...)
+	}
+
+	// Request based metrics provided by rqmc
+	a.rqmc.AddApiRequest(attrs...)
+	var bytes int64
+	if msg, ok := resp.(proto.Message); ok {
+		bytes = int64(proto.Size(msg))
+	}
+	a.rqmc.UpdateTotalBytesSent(ctx, bytes, attrs...)
+	a.rqmc.UpdateLatencyTime(ctx, latency, attrs...)
+	return resp, err
+}
+
+// splitFullMethod splits a full gRPC method name of the form /service/method.
+func splitFullMethod(fullMethod string) (string, string) {
+	name := strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return "", name
+}
+
+// callGrpc calls the OutgoingSampleApp method of the peer sample app listening on port and describes its outcome.
+func (a *App) callGrpc(ctx context.Context, port string) (result downstreamResult) {
+	// Consider making requests on other than localhost
+	target := net.JoinHostPort("0.0.0.0", port)
+	method := "/" + grpcServiceName + "/OutgoingSampleApp"
+	result.URL = "grpc://" + target + method
+	start := time.Now()
+	defer func() {
+		result.DurationMs = time.Since(start).Milliseconds()
+	}()
+
+	conn, err := a.grpcConn(target)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	out := &structpb.Struct{}
+	err = conn.Invoke(ctx, method, &structpb.Struct{}, out)
+	result.GrpcStatus = status.Code(err).String()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if peer, err := structToResponse(out); err == nil && peer.TraceID != "" {
+		result.Response = peer
+	}
+	return result
+}
+
+// grpcConn returns the client connection to target, creating it on first use. Connections are closed on Shutdown.
+func (a *App) grpcConn(target string) (*grpc.ClientConn, error) {
+	a.grpcMu.Lock()
+	defer a.grpcMu.Unlock()
+	if conn, ok := a.grpcConns[target]; ok {
+		return conn, nil
+	}
+	conn, err := grpc.Dial(target, a.grpcDialOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	if a.grpcConns == nil {
+		a.grpcConns = map[string]*grpc.ClientConn{}
+	}
+	a.grpcConns[target] = conn
+	return conn, nil
+}
+
+// grpcDialOptions returns the options of the client connections to the peer sample apps, whose calls are traced by
+// otelgrpc.
+func (a *App) grpcDialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor(
+			otelgrpc.WithTracerProvider(a.tp),
+			otelgrpc.WithMeterProvider(a.mp),
+			otelgrpc.WithPropagators(a.propagator),
+		)),
+	}
+}
+
+// closeGrpcConns closes the client connections to the peer sample apps.
+func (a *App) closeGrpcConns() {
+	a.grpcMu.Lock()
+	defer a.grpcMu.Unlock()
+	for target, conn := range a.grpcConns {
+		conn.Close()
+		delete(a.grpcConns, target)
+	}
+}
+
+// responseToStruct converts resp to the Struct returned by the gRPC service, with the same fields as the JSON body.
+func responseToStruct(resp response) (*structpb.Struct, error) {
+	payload, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, err
+	}
+	return structpb.NewStruct(fields)
+}
+
+// structToResponse converts a Struct returned by a peer sample app to a response.
+func structToResponse(s *structpb.Struct) (*response, error) {
+	payload, err := json.Marshal(s.AsMap())
+	if err != nil {
+		return nil, err
+	}
+	resp := &response{}
+	if err := json.Unmarshal(payload, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,140 @@
+package collection
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// roundTripFunc is an http.RoundTripper answering with a function.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// serveGrpc serves the gRPC service of ta on an in-memory listener and returns a client connection of caller to it,
+// registered as the connection to the peer on port.
+func serveGrpc(t *testing.T, ta, caller *testApp, port string) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := ta.GRPCServer()
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	target := net.JoinHostPort("0.0.0.0", port)
+	conn, err := grpc.Dial(target, append(caller.grpcDialOptions(), grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	}))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caller.grpcMu.Lock()
+	caller.grpcConns = map[string]*grpc.ClientConn{target: conn}
+	caller.grpcMu.Unlock()
+	return conn
+}
+
+// grpcSpan returns the span of kind for the method of the sample app service.
+func grpcSpan(t *testing.T, spans *tracetest.InMemoryExporter, kind trace.SpanKind, method string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range spans.GetSpans() {
+		if span.SpanKind == kind && spanAttr(span, semconv.RPCMethodKey).AsString() == method {
+			return span
+		}
+	}
+	t.Fatalf("no %s span for %s", kind, method)
+	return tracetest.SpanStub{}
+}
+
+func TestGrpc(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.SampleAppPorts = nil
+	cfg.RequestMetricAttributes.StatusCode = true
+	leaf := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("")), Request: r}, nil
+	})}
+	server := newTestApp(t, cfg, WithHTTPClient(leaf))
+	if err := server.rqmc.StartTotalRequestCallback(); err != nil {
+		t.Fatal(err)
+	}
+
+	callerCfg := testConfig(t)
+	callerCfg.SampleAppPorts = []string{"4319"}
+	callerCfg.SampleAppProtocol = protocolGrpc
+	caller := newTestApp(t, callerCfg)
+	conn := serveGrpc(t, server, caller, "4319")
+
+	t.Run("call", func(t *testing.T) {
+		result := caller.callGrpc(context.Background(), "4319")
+		if result.GrpcStatus != codes.OK.String() || result.Error != "" || result.Response == nil {
+			t.Fatalf("callGrpc() = %+v, want a successful call", result)
+		}
+		client := grpcSpan(t, caller.spans, trace.SpanKindClient, "OutgoingSampleApp")
+		srv := grpcSpan(t, server.spans, trace.SpanKindServer, "OutgoingSampleApp")
+		if srv.SpanContext.TraceID() != client.SpanContext.TraceID() {
+			t.Errorf("server trace %s, want the trace of the client %s", srv.SpanContext.TraceID(), client.SpanContext.TraceID())
+		}
+		for _, kv := range []attribute.KeyValue{semconv.RPCSystemGRPC, semconv.RPCService(grpcServiceName)} {
+			if got := spanAttr(srv, kv.Key); got != kv.Value {
+				t.Errorf("server span %s = %q, want %q", kv.Key, got.Emit(), kv.Value.Emit())
+			}
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		req, _ := structpb.NewStruct(map[string]interface{}{"depth": 0})
+		err := conn.Invoke(context.Background(), "/"+grpcServiceName+"/SyntheticTrace", req, &structpb.Struct{})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("SyntheticTrace with depth 0 = %v, want InvalidArgument", err)
+		}
+		if got := grpcSpan(t, server.spans, trace.SpanKindServer, "SyntheticTrace"); got.Status.Code.String() != "Error" {
+			t.Errorf("server span status = %v, want Error", got.Status)
+		}
+
+		result := caller.callGrpc(context.Background(), "1")
+		if result.GrpcStatus != codes.Unavailable.String() || result.Error == "" {
+			t.Errorf("callGrpc() to a closed port = %+v, want Unavailable", result)
+		}
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		metrics := server.metrics(t)
+		requests, ok := metrics[totalApiRequests].Data.(metricdata.Sum[int64])
+		if !ok {
+			t.Fatalf("%s is missing", totalApiRequests)
+		}
+		codesByMethod := map[string]int64{}
+		for _, dp := range requests.DataPoints {
+			if system, _ := dp.Attributes.Value(semconv.RPCSystemKey); system.AsString() != "grpc" {
+				continue
+			}
+			method, _ := dp.Attributes.Value(semconv.RPCMethodKey)
+			code, _ := dp.Attributes.Value(semconv.RPCGRPCStatusCodeKey)
+			codesByMethod[method.AsString()] = code.AsInt64()
+		}
+		if got, ok := codesByMethod["OutgoingSampleApp"]; !ok || got != int64(codes.OK) {
+			t.Errorf("OutgoingSampleApp requests status code = %d (%v), want 0", got, ok)
+		}
+		if got, ok := codesByMethod["SyntheticTrace"]; !ok || got != int64(codes.InvalidArgument) {
+			t.Errorf("SyntheticTrace requests status code = %d (%v), want 3", got, ok)
+		}
+		if _, ok := metrics["rpc.server.duration"]; !ok {
+			t.Errorf("rpc.server.duration of otelgrpc is missing")
+		}
+	})
+}
@@ -0,0 +1,218 @@
+package collection
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Contains the health and readiness endpoint logic.
+
+const defaultCollectorEndpoint = "localhost:4317"
+const defaultCollectorPort = "4317"
+const probeTimeout = 2 * time.Second
+
+// exportStatus keeps track of the outcome of the most recent export of a signal and of the export totals.
+type exportStatus struct {
+	mu        sync.Mutex
+	attempted bool
+	lastTime  time.Time
+	lastErr   error
+	exports   int64
+	failures  int64
+	items     int64
+}
+
+// record stores the result of an export of n items.
+func (es *exportStatus) record(err error, n int) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	es.attempted = true
+	es.lastTime = time.Now()
+	es.lastErr = err
+	es.exports++
+	if err != nil {
+		es.failures++
+	} else {
+		es.items += int64(n)
+	}
+}
+
+// check reports the last export result. A pipeline that has not exported yet is considered healthy.
+func (es *exportStatus) check() checkResult {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if !es.attempted {
+		return checkResult{OK: true, Detail: "no export attempted yet"}
+	}
+	res := checkResult{OK: es.lastErr == nil, LastExport: es.lastTime.UTC().Format(time.RFC3339)}
+	if es.lastErr != nil {
+		res.Error = es.lastErr.Error()
+	}
+	return res
+}
+
+// statusSpanExporter wraps a span exporter and records the result of every export.
+type statusSpanExporter struct {
+	sdktrace.SpanExporter
+	status *exportStatus
+}
+
+func (e statusSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	e.status.record(err, len(spans))
+	return err
+}
+
+// statusMetricExporter wraps a metric exporter and records the result of every export.
+type statusMetricExporter struct {
+	metric.Exporter
+	status *exportStatus
+}
+
+func (e statusMetricExporter) Export(ctx context.Context, rm metricdata.ResourceMetrics) error {
+	err := e.Exporter.Export(ctx, rm)
+	n := 0
+	for _, sm := range rm.ScopeMetrics {
+		n += len(sm.Metrics)
+	}
+	e.status.record(err, n)
+	return err
+}
+
+// checkResult is the JSON representation of a single readiness check.
+type checkResult struct {
+	OK         bool   `json:"ok"`
+	Target     string `json:"target,omitempty"`
+	LastExport string `json:"lastExport,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+type healthResponse struct {
+	Status string                 `json:"status"`
+	Uptime string                 `json:"uptime"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+// Healthz reports that the process is alive. It does not check any dependencies.
+func (a *App) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeHealthResponse(w, http.StatusOK, healthResponse{
+		Status: "ok",
+		Uptime: time.Since(a.startTime).Round(time.Second).String(),
+	})
+}
+
+// Readyz reports whether telemetry is flowing: the destinations of the trace and metric exporters started by the
+// App must be reachable, the last trace and metric exports must have succeeded and every configured sample app peer
+// must be reachable. The destinations of injected providers are not probed.
+func (a *App) Readyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]checkResult{
+		"traces":  a.traceStatus.check(),
+		"metrics": a.metricStatus.check(),
+	}
+	if a.metricProbe != nil {
+		checks["metricsEndpoint"] = dialCheck(r.Context(), a.metricProbe.network, a.metricProbe.addr)
+	}
+	if a.traceProbe != nil {
+		checks["tracesEndpoint"] = dialCheck(r.Context(), a.traceProbe.network, a.traceProbe.addr)
+	}
+	if a.logs != nil {
+		checks["logs"] = a.logStatus.check()
+	}
+	for _, port := range a.cfg.SampleAppPorts {
+		if port != "" {
+			checks["peer:"+port] = dialCheck(r.Context(), "tcp", net.JoinHostPort("0.0.0.0", port))
+		}
+	}
+
+	status, code := "ready", http.StatusOK
+	for _, c := range checks {
+		if !c.OK {
+			status, code = "unready", http.StatusServiceUnavailable
+			break
+		}
+	}
+	writeHealthResponse(w, code, healthResponse{
+		Status: status,
+		Uptime: time.Since(a.startTime).Round(time.Second).String(),
+		Checks: checks,
+	})
+}
+
+// collectorEndpoint returns the host:port of the OTLP receiver the gRPC exporter of signal (TRACES or METRICS) sends
+// to: OTEL_EXPORTER_OTLP_<signal>_ENDPOINT, then OTEL_EXPORTER_OTLP_ENDPOINT, with the OTLP gRPC port by default.
+func collectorEndpoint(signal string) string {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_" + signal + "_ENDPOINT")
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	if endpoint == "" {
+		return defaultCollectorEndpoint
+	}
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		endpoint = u.Host
+	}
+	if _, _, err := net.SplitHostPort(endpoint); err != nil {
+		return net.JoinHostPort(strings.Trim(endpoint, "[]"), defaultCollectorPort)
+	}
+	return endpoint
+}
+
+// endpointAddress returns the host:port of an HTTP endpoint URL, with the default port of its scheme.
+func endpointAddress(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return endpoint
+	}
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "443"
+	if u.Scheme == "http" {
+		port = "80"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// probeTarget is an address checked by Readyz.
+type probeTarget struct {
+	network string
+	addr    string
+}
+
+// dialCheck connects to addr over network to verify that it is reachable. UDP is connectionless, so for UDP
+// addresses the check only verifies that the address resolves.
+func dialCheck(ctx context.Context, network, addr string) checkResult {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return checkResult{OK: false, Target: addr, Error: err.Error()}
+	}
+	conn.Close()
+	res := checkResult{OK: true, Target: addr}
+	if network == "udp" {
+		res.Detail = "UDP address resolved, delivery is not acknowledged"
+	}
+	return res
+}
+
+func writeHealthResponse(w http.ResponseWriter, code int, resp healthResponse) {
+	payload, _ := json.Marshal(resp)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(payload)
+}
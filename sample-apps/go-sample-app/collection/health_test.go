@@ -0,0 +1,95 @@
+package collection
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestReadyz(t *testing.T) {
+	collector, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer collector.Close()
+	daemon, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer daemon.Close()
+
+	cfg := testConfig(t)
+	cfg.SampleAppPorts = nil
+	ta := newTestApp(t, cfg)
+	readyz := func() (int, healthResponse) {
+		rec := ta.serve(t, http.MethodGet, "/readyz")
+		var resp healthResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, resp
+	}
+
+	// The destinations of injected providers are unknown
+	code, resp := readyz()
+	if code != http.StatusOK {
+		t.Fatalf("GET /readyz = %d %+v, want 200", code, resp)
+	}
+	for _, name := range []string{"metricsEndpoint", "tracesEndpoint"} {
+		if _, ok := resp.Checks[name]; ok {
+			t.Errorf("%s is probed for an injected provider", name)
+		}
+	}
+
+	ta.metricProbe = &probeTarget{network: "tcp", addr: collector.Addr().String()}
+	ta.traceProbe = &probeTarget{network: "udp", addr: daemon.LocalAddr().String()}
+	code, resp = readyz()
+	if code != http.StatusOK {
+		t.Fatalf("GET /readyz = %d %+v, want 200", code, resp)
+	}
+	if got := resp.Checks["metricsEndpoint"].Target; got != collector.Addr().String() {
+		t.Errorf("metricsEndpoint target = %q, want the OTLP receiver %s", got, collector.Addr())
+	}
+	if got := resp.Checks["tracesEndpoint"].Target; got != daemon.LocalAddr().String() {
+		t.Errorf("tracesEndpoint target = %q, want the daemon %s", got, daemon.LocalAddr())
+	}
+
+	collector.Close()
+	if code, _ := readyz(); code != http.StatusServiceUnavailable {
+		t.Errorf("GET /readyz without OTLP receiver = %d, want 503", code)
+	}
+}
+
+func TestCollectorEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint, metricsEndpoint, want string
+	}{
+		{"", "", "localhost:4317"},
+		{"http://collector:4317", "", "collector:4317"},
+		{"http://collector", "", "collector:4317"},
+		{"collector", "", "collector:4317"},
+		{"http://collector:4317", "https://metrics:5317", "metrics:5317"},
+		{"", "http://[::1]", "[::1]:4317"},
+	}
+	for _, tt := range tests {
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", tt.endpoint)
+		t.Setenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", tt.metricsEndpoint)
+		if got := collectorEndpoint("METRICS"); got != tt.want {
+			t.Errorf("collectorEndpoint(METRICS) with %q and %q = %q, want %q", tt.endpoint, tt.metricsEndpoint, got, tt.want)
+		}
+	}
+}
+
+func TestEndpointAddress(t *testing.T) {
+	tests := map[string]string{
+		"https://xray.us-west-2.amazonaws.com/v1/traces": "xray.us-west-2.amazonaws.com:443",
+		"http://localhost/v1/traces":                     "localhost:80",
+		"http://127.0.0.1:4318/v1/traces":                "127.0.0.1:4318",
+	}
+	for endpoint, want := range tests {
+		if got := endpointAddress(endpoint); got != want {
+			t.Errorf("endpointAddress(%q) = %q, want %q", endpoint, got, want)
+		}
+	}
+}
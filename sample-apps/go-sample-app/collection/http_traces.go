@@ -4,18 +4,39 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
 
 // Contains all of the endpoint logic.
 
+// response is the JSON body returned by the endpoints. Downstream holds the result of every outgoing call, including
+// the response of peer sample apps, so a single request shows the whole invocation tree. Padding pads the body of the
+// custom endpoints to their configured response size.
 type response struct {
-	TraceID string `json:"traceId"`
+	TraceID    string             `json:"traceId"`
+	SpanID     string             `json:"spanId"`
+	Sampled    bool               `json:"sampled"`
+	Downstream []downstreamResult `json:"downstream,omitempty"`
+	Padding    string             `json:"padding,omitempty"`
+}
+
+// downstreamResult describes an outgoing call made while serving a request.
+type downstreamResult struct {
+	URL        string    `json:"url"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	DurationMs int64     `json:"durationMs"`
+	GrpcStatus string    `json:"grpcStatus,omitempty"`
+	Response   *response `json:"response,omitempty"`
+	Error      string    `json:"error,omitempty"`
 }
 
 type s3Client struct {
@@ -33,137 +54,184 @@ func NewS3Client() (*s3Client, error) {
 
 // AwsSdkCall mocks a request to s3. ListBuckets are nil so no credentials are needed.
 // Generates an Xray Trace ID.
-func AwsSdkCall(w http.ResponseWriter, r *http.Request, rqmc *requestBasedMetricCollector, s3 *s3Client) {
-	w.Header().Set("Content-Type", "application/json")
+func (a *App) AwsSdkCall(w http.ResponseWriter, r *http.Request) {
+	a.writeResponse(w, a.awsSdkCall(r.Context()))
+}
 
-	s3.client.ListBuckets(nil) // nil or else would need real aws credentials
+// awsSdkCall is the operation behind AwsSdkCall, shared by the HTTP and gRPC servers.
+func (a *App) awsSdkCall(ctx context.Context) response {
+	a.s3.client.ListBuckets(nil) // nil or else would need real aws credentials
 
-	ctx, span := tracer.Start(
-		r.Context(),
+	_, span := a.tracer.Start(
+		ctx,
 		"aws-sdk-call",
-		trace.WithAttributes(traceCommonLabels...),
+		trace.WithAttributes(a.traceLabels...),
 	)
 	defer span.End()
 
-	// Request based metrics provided by rqmc
-	rqmc.AddApiRequest()
-	rqmc.UpdateTotalBytesSent(ctx)
-	rqmc.UpdateLatencyTime(ctx)
-
-	writeResponse(span, w)
+	return a.newResponse(span, nil)
 }
 
 // OutgoingSampleApp makes a request to another Sampleapp and generates an Xray Trace ID. It will also make a request to amazon.com.
-func OutgoingSampleApp(w http.ResponseWriter, r *http.Request, client http.Client, rqmc *requestBasedMetricCollector) {
+func (a *App) OutgoingSampleApp(w http.ResponseWriter, r *http.Request) {
+	a.writeResponse(w, a.outgoingSampleApp(r.Context()))
+}
+
+// outgoingSampleApp is the operation behind OutgoingSampleApp, shared by the HTTP and gRPC servers.
+func (a *App) outgoingSampleApp(ctx context.Context) response {
 
-	ctx, span := tracer.Start(
-		r.Context(),
+	ctx, span := a.tracer.Start(
+		ctx,
 		"invoke-sample-apps",
-		trace.WithAttributes(traceCommonLabels...),
+		trace.WithAttributes(a.traceLabels...),
 	)
 	defer span.End()
-	count := len(rqmc.config.SampleAppPorts)
+	count := len(a.cfg.SampleAppPorts)
 
+	var downstream []downstreamResult
 	// If there are no sample app port list is empty then make a request to amazon.com (leaf request)
 	if count == 0 {
-		ctx, span := tracer.Start(
+		ctx, span := a.tracer.Start(
 			ctx,
 			"leaf-request",
-			trace.WithAttributes(traceCommonLabels...),
+			trace.WithAttributes(a.traceLabels...),
 		)
 
-		req, _ := http.NewRequestWithContext(ctx, "GET", "https://aws.amazon.com", nil)
-		res, err := client.Do(req)
-		if err != nil {
-			fmt.Println(err)
-		}
-
-		defer res.Body.Close()
-		// Request based metrics provided by rqmc
-		rqmc.AddApiRequest()
-		rqmc.UpdateTotalBytesSent(ctx)
-		rqmc.UpdateLatencyTime(ctx)
-
+		downstream = append(downstream, a.call(ctx, "https://aws.amazon.com"))
 		span.End()
 
 	} else { // If there are sample app ports to make a request to (chain request)
-		invokeSampleApps(ctx, client, rqmc)
+		downstream = a.invokeSampleApps(ctx)
 	}
-	writeResponse(span, w)
-
+	return a.newResponse(span, downstream)
 }
 
 // invokeSampleApps loops through the list of sample app ports provided in the configuration file and makes a call to invoke().
-func invokeSampleApps(ctx context.Context, client http.Client, rqmc *requestBasedMetricCollector) {
+func (a *App) invokeSampleApps(ctx context.Context) []downstreamResult {
 
-	for _, port := range rqmc.config.SampleAppPorts {
+	var results []downstreamResult
+	for _, port := range a.cfg.SampleAppPorts {
 		if port != "" {
-			invoke(ctx, port, client)
+			results = append(results, a.invoke(ctx, port))
 		}
 	}
+	return results
 }
 
-// invoke uses the port given in the parameters to make an http request.
-func invoke(ctx context.Context, port string, client http.Client) {
+// invoke uses the port given in the parameters to make an http request, or a gRPC call when the sample app protocol is
+// grpc.
+func (a *App) invoke(ctx context.Context, port string) downstreamResult {
 
-	ctx, span := tracer.Start(
+	ctx, span := a.tracer.Start(
 		ctx,
 		"invoke-sample-app",
-		trace.WithAttributes(traceCommonLabels...),
+		trace.WithAttributes(a.traceLabels...),
 	)
+	defer span.End()
+
+	if a.cfg.SampleAppProtocol == protocolGrpc {
+		return a.callGrpc(ctx, port)
+	}
+
 	// Consider making requests on other than localhost
 	addr := "http://" + net.JoinHostPort("0.0.0.0", port) + "/outgoing-sampleapp"
 	fmt.Println(addr)
-	req, _ := http.NewRequestWithContext(ctx, "GET", addr, nil)
-	res, err := client.Do(req)
+	return a.call(ctx, addr)
+}
+
+// call makes an http GET request to url and describes its outcome. When the body is a sample app response, it is
+// attached to the result.
+func (a *App) call(ctx context.Context, url string) (result downstreamResult) {
+	result.URL = url
+	start := time.Now()
+	defer func() {
+		result.DurationMs = time.Since(start).Milliseconds()
+	}()
 
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	res, err := a.client.Do(req)
 	if err != nil {
 		fmt.Println(err)
+		trace.SpanFromContext(ctx).SetStatus(codes.Error, err.Error())
+		result.Error = err.Error()
+		return result
 	}
-
 	defer res.Body.Close()
-	defer span.End()
 
+	result.StatusCode = res.StatusCode
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") {
+		peer := &response{}
+		if json.Unmarshal(body, peer) == nil && peer.TraceID != "" {
+			result.Response = peer
+		}
+	}
+	return result
 }
 
 // OutgoingHttpCall makes an HTTP GET request to https://aws.amazon.com/ and generates an Xray Trace ID.
-func OutgoingHttpCall(w http.ResponseWriter, r *http.Request, client http.Client, rqmc *requestBasedMetricCollector) {
+func (a *App) OutgoingHttpCall(w http.ResponseWriter, r *http.Request) {
+	a.writeResponse(w, a.outgoingHttpCall(r.Context()))
+}
 
-	w.Header().Set("Content-Type", "application/json")
+// outgoingHttpCall is the operation behind OutgoingHttpCall, shared by the HTTP and gRPC servers.
+func (a *App) outgoingHttpCall(ctx context.Context) response {
 
-	ctx, span := tracer.Start(
-		r.Context(),
+	ctx, span := a.tracer.Start(
+		ctx,
 		"outgoing-http-call",
-		trace.WithAttributes(traceCommonLabels...),
+		trace.WithAttributes(a.traceLabels...),
 	)
 
 	defer span.End()
 
-	req, _ := http.NewRequestWithContext(ctx, "GET", "https://aws.amazon.com/", nil)
-	res, err := client.Do(req)
-	if err != nil {
-		fmt.Println(err)
-	}
+	result := a.call(ctx, "https://aws.amazon.com/")
+	return a.newResponse(span, []downstreamResult{result})
+}
 
-	defer res.Body.Close()
+// getXrayTraceID generates a trace ID in Xray format from the span context.
+func getXrayTraceID(span trace.Span) string {
+	return xrayTraceID(span.SpanContext().TraceID())
+}
 
-	// Request based metrics provided by rqmc
-	rqmc.AddApiRequest()
-	rqmc.UpdateTotalBytesSent(ctx)
-	rqmc.UpdateLatencyTime(ctx)
-	writeResponse(span, w)
+// xrayTraceID formats a trace ID in Xray format.
+func xrayTraceID(traceID trace.TraceID) string {
+	id := traceID.String()
+	return fmt.Sprintf("1-%s-%s", id[0:8], id[8:])
+}
 
+// newResponse describes the trace context of span and the results of the downstream calls. The trace ID is in Xray
+// format unless the W3C format is configured.
+func (a *App) newResponse(span trace.Span, downstream []downstreamResult) response {
+	traceID := getXrayTraceID(span)
+	if a.cfg.ResponseTraceIdFormat == traceIdFormatW3C {
+		traceID = span.SpanContext().TraceID().String()
+	}
+	return response{
+		TraceID:    traceID,
+		SpanID:     span.SpanContext().SpanID().String(),
+		Sampled:    span.SpanContext().IsSampled(),
+		Downstream: downstream,
+	}
 }
 
-// getXrayTraceID generates a trace ID in Xray format from the span context.
-func getXrayTraceID(span trace.Span) string {
-	xrayTraceID := span.SpanContext().TraceID().String()
-	return fmt.Sprintf("1-%s-%s", xrayTraceID[0:8], xrayTraceID[8:])
+// writeResponse writes resp as JSON.
+func (a *App) writeResponse(w http.ResponseWriter, resp response) {
+	a.writeResponseStatus(w, http.StatusOK, resp)
 }
 
-func writeResponse(span trace.Span, w http.ResponseWriter) {
-	xrayTraceID := getXrayTraceID(span)
-	payload, _ := json.Marshal(response{TraceID: xrayTraceID})
+// writeResponseStatus writes resp as JSON with the given status code.
+func (a *App) writeResponseStatus(w http.ResponseWriter, status int, resp response) {
+	payload, _ := json.Marshal(resp)
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	w.Write(payload)
 }
@@ -0,0 +1,183 @@
+package collection
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Contains the scheduled background jobs.
+
+// Overlap policies, deciding what happens when a job is due while its previous run is still in progress.
+const (
+	overlapAllow = "allow"
+	overlapSkip  = "skip"
+	overlapDelay = "delay"
+)
+
+// Outcomes of a job run.
+const (
+	jobSuccess   = "success"
+	jobError     = "error"
+	jobSkipped   = "skipped"
+	jobCancelled = "cancelled"
+)
+
+// jobScheduleParser accepts standard cron specs with an optional seconds field and descriptors such as @every 10s.
+var jobScheduleParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+var jobMetricCommonLabels = []attribute.KeyValue{
+	attribute.String("signal", "metric"),
+	attribute.String("language", serviceName),
+	attribute.String("metricType", "job"),
+}
+
+// job is a configured background job.
+type job struct {
+	cfg      JobConfig
+	schedule cron.Schedule
+	running  int32
+	mu       sync.Mutex
+}
+
+// jobScheduler runs the background jobs of an App on their schedules.
+type jobScheduler struct {
+	app      *App
+	jobs     []*job
+	labels   []attribute.KeyValue
+	duration instrument.Float64Histogram
+	runs     instrument.Int64Counter
+}
+
+// newJobScheduler creates the configured jobs, which Config.Validate has checked. nameSuffix is appended to the
+// metric names, attrs are added to the common attributes of the job metrics.
+func newJobScheduler(a *App, mp metric.MeterProvider, configs []JobConfig, nameSuffix string, attrs ...attribute.KeyValue) (*jobScheduler, error) {
+	s := &jobScheduler{app: a, labels: metricLabels(jobMetricCommonLabels, attrs)}
+	for _, cfg := range configs {
+		schedule, err := jobScheduleParser.Parse(cfg.Schedule)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.OverlapPolicy == "" {
+			cfg.OverlapPolicy = overlapSkip
+		}
+		s.jobs = append(s.jobs, &job{cfg: cfg, schedule: schedule})
+	}
+
+	meter := mp.Meter(instrumentationName)
+	var err error
+	if s.duration, err = meter.Float64Histogram(
+		jobDuration+nameSuffix,
+		instrument.WithDescription("Measures the duration of the background job runs"),
+		instrument.WithUnit("ms"),
+	); err != nil {
+		return nil, err
+	}
+	if s.runs, err = meter.Int64Counter(
+		jobRuns+nameSuffix,
+		instrument.WithDescription("Counts the background job runs by outcome"),
+		instrument.WithUnit("{run}"),
+	); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// start schedules the jobs until ctx is done.
+func (s *jobScheduler) start(ctx context.Context) {
+	if len(s.jobs) == 0 {
+		return
+	}
+	c := cron.New(cron.WithParser(jobScheduleParser))
+	for _, j := range s.jobs {
+		j := j
+		c.Schedule(j.schedule, cron.FuncJob(func() { s.trigger(ctx, j) }))
+	}
+	c.Start()
+	go func() {
+		<-ctx.Done()
+		c.Stop()
+	}()
+}
+
+// trigger applies the overlap policy of j and runs it.
+func (s *jobScheduler) trigger(ctx context.Context, j *job) {
+	switch j.cfg.OverlapPolicy {
+	case overlapSkip:
+		if !atomic.CompareAndSwapInt32(&j.running, 0, 1) {
+			s.runs.Add(ctx, 1, s.attributes(j, jobSkipped)...)
+			return
+		}
+		defer atomic.StoreInt32(&j.running, 0)
+	case overlapDelay:
+		j.mu.Lock()
+		defer j.mu.Unlock()
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	s.run(ctx, j)
+}
+
+// run runs j in its own root INTERNAL span: it simulates Duration milliseconds of work and then makes the
+// configured outgoing calls. The run fails when a call fails or returns a server error, and is cancelled without
+// making its calls when ctx is done during the simulated work.
+func (s *jobScheduler) run(ctx context.Context, j *job) {
+	a := s.app
+	ctx, span := a.tracer.Start(ctx, "job "+j.cfg.Name,
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(a.traceLabels...),
+		trace.WithAttributes(
+			attribute.String("job.name", j.cfg.Name),
+			attribute.String("job.schedule", j.cfg.Schedule),
+			attribute.String("job.overlap_policy", j.cfg.OverlapPolicy),
+		),
+	)
+	start := time.Now()
+
+	outcome := jobSuccess
+	// Simulated work
+	select {
+	case <-ctx.Done():
+		outcome = jobCancelled
+		span.SetStatus(codes.Error, ctx.Err().Error())
+	case <-time.After(time.Duration(j.cfg.Duration) * time.Millisecond):
+		for _, url := range j.cfg.Calls {
+			result := a.call(ctx, url)
+			if result.Error != "" || result.StatusCode >= 500 {
+				outcome = jobError
+				span.SetStatus(codes.Error, fmt.Sprintf("call to %s failed", url))
+			}
+		}
+	}
+	span.SetAttributes(attribute.String("job.outcome", outcome))
+	span.End()
+
+	// The SDK drops measurements made with a done context, which is the case of the cancelled runs
+	if outcome == jobCancelled {
+		ctx = context.Background()
+	}
+	attrs := s.attributes(j, outcome)
+	s.duration.Record(ctx, float64(time.Since(start).Microseconds())/1000, attrs...)
+	s.runs.Add(ctx, 1, attrs...)
+}
+
+// attributes returns the metric attributes of a run of j.
+func (s *jobScheduler) attributes(j *job, outcome string) []attribute.KeyValue {
+	return metricLabels(s.labels, []attribute.KeyValue{
+		attribute.String("job.name", j.cfg.Name),
+		attribute.String("job.outcome", outcome),
+	})
+}
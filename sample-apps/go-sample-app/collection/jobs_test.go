@@ -0,0 +1,59 @@
+package collection
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestJobMetricAttributes(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Jobs = []JobConfig{{Name: "nightly", Schedule: "@daily"}}
+	ta := newTestApp(t, cfg)
+	ta.jobs.run(context.Background(), ta.jobs.jobs[0])
+
+	runs, ok := ta.metrics(t)[jobRuns].Data.(metricdata.Sum[int64])
+	if !ok || len(runs.DataPoints) != 1 {
+		t.Fatalf("%s has no single data point: %+v", jobRuns, runs)
+	}
+	attrs := runs.DataPoints[0].Attributes
+	for _, want := range []attribute.KeyValue{
+		attribute.String("metricType", "job"),
+		attribute.String("job.name", "nightly"),
+		attribute.String("job.outcome", jobSuccess),
+	} {
+		if got, _ := attrs.Value(want.Key); got != want.Value {
+			t.Errorf("%s = %q, want %q", want.Key, got.Emit(), want.Value.Emit())
+		}
+	}
+}
+
+func TestJobCancelled(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Jobs = []JobConfig{{Name: "slow", Schedule: "@daily", Duration: int64(time.Hour / time.Millisecond)}}
+	ta := newTestApp(t, cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		ta.jobs.run(ctx, ta.jobs.jobs[0])
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job did not stop with its context")
+	}
+
+	runs, ok := ta.metrics(t)[jobRuns].Data.(metricdata.Sum[int64])
+	if !ok || len(runs.DataPoints) != 1 {
+		t.Fatalf("%s has no single data point: %+v", jobRuns, runs)
+	}
+	if got, _ := runs.DataPoints[0].Attributes.Value("job.outcome"); got.AsString() != jobCancelled {
+		t.Errorf("job.outcome = %q, want %s", got.Emit(), jobCancelled)
+	}
+}
@@ -0,0 +1,205 @@
+package collection
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Contains the logic to serve the sample app endpoints from AWS Lambda.
+
+// xrayTraceEnvVar is set by the Lambda runtime to the X-Ray trace header of the current invocation.
+const xrayTraceEnvVar = "_X_AMZN_TRACE_ID"
+
+// LambdaHandler returns a Lambda handler which serves API Gateway (REST and HTTP API) and ALB events with the same
+// routes as the web server. The providers are flushed at the end of every invocation since the execution environment
+// may be frozen afterwards.
+func (a *App) LambdaHandler() func(ctx context.Context, event json.RawMessage) (interface{}, error) {
+	handler := a.Handler()
+	return func(ctx context.Context, event json.RawMessage) (interface{}, error) {
+		defer func() {
+			if err := a.ForceFlush(ctx); err != nil {
+				fmt.Println(err)
+			}
+		}()
+
+		var probe struct {
+			Version        string `json:"version"`
+			RequestContext struct {
+				ELB *json.RawMessage `json:"elb"`
+			} `json:"requestContext"`
+		}
+		if err := json.Unmarshal(event, &probe); err != nil {
+			return nil, fmt.Errorf("decoding event: %w", err)
+		}
+
+		switch {
+		case probe.Version == "2.0":
+			var req events.APIGatewayV2HTTPRequest
+			if err := json.Unmarshal(event, &req); err != nil {
+				return nil, err
+			}
+			rec, err := serveLambdaRequest(ctx, handler, req.RequestContext.HTTP.Method, req.RawPath, req.RawQueryString,
+				req.Headers, nil, req.Body, req.IsBase64Encoded)
+			if err != nil {
+				return nil, err
+			}
+			body, isBase64 := lambdaBody(rec)
+			// HTTP APIs only read the single value headers, cookies are returned separately
+			return events.APIGatewayV2HTTPResponse{
+				StatusCode:      rec.Code,
+				Headers:         lambdaHeaders(rec.Header(), "Set-Cookie"),
+				Cookies:         rec.Header().Values("Set-Cookie"),
+				Body:            body,
+				IsBase64Encoded: isBase64,
+			}, nil
+		case probe.RequestContext.ELB != nil:
+			var req events.ALBTargetGroupRequest
+			if err := json.Unmarshal(event, &req); err != nil {
+				return nil, err
+			}
+			rec, err := serveLambdaRequest(ctx, handler, req.HTTPMethod, req.Path, lambdaQuery(req.QueryStringParameters, req.MultiValueQueryStringParameters),
+				req.Headers, req.MultiValueHeaders, req.Body, req.IsBase64Encoded)
+			if err != nil {
+				return nil, err
+			}
+			body, isBase64 := lambdaBody(rec)
+			res := events.ALBTargetGroupResponse{
+				StatusCode:        rec.Code,
+				StatusDescription: fmt.Sprintf("%d %s", rec.Code, http.StatusText(rec.Code)),
+				Body:              body,
+				IsBase64Encoded:   isBase64,
+			}
+			// The target group answers in the format of the request, depending on whether multi value headers are enabled
+			if req.MultiValueHeaders != nil {
+				res.MultiValueHeaders = rec.Header()
+			} else {
+				res.Headers = lambdaHeaders(rec.Header())
+			}
+			return res, nil
+		default:
+			var req events.APIGatewayProxyRequest
+			if err := json.Unmarshal(event, &req); err != nil {
+				return nil, err
+			}
+			rec, err := serveLambdaRequest(ctx, handler, req.HTTPMethod, req.Path, lambdaQuery(req.QueryStringParameters, req.MultiValueQueryStringParameters),
+				req.Headers, req.MultiValueHeaders, req.Body, req.IsBase64Encoded)
+			if err != nil {
+				return nil, err
+			}
+			body, isBase64 := lambdaBody(rec)
+			return events.APIGatewayProxyResponse{
+				StatusCode:        rec.Code,
+				MultiValueHeaders: rec.Header(),
+				Body:              body,
+				IsBase64Encoded:   isBase64,
+			}, nil
+		}
+	}
+}
+
+// serveLambdaRequest builds an http request from the fields of a Lambda event and serves it with handler.
+func serveLambdaRequest(ctx context.Context, handler http.Handler, method, path, query string, headers map[string]string,
+	multiValueHeaders map[string][]string, body string, isBase64 bool) (*httptest.ResponseRecorder, error) {
+
+	var payload []byte
+	if isBase64 {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return nil, fmt.Errorf("decoding body: %w", err)
+		}
+		payload = decoded
+	} else {
+		payload = []byte(body)
+	}
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	u := url.URL{Path: path, RawQuery: query}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.RequestURI = u.RequestURI()
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	for k, values := range multiValueHeaders {
+		req.Header.Del(k)
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Host = req.Header.Get("Host")
+
+	// Continue the trace started by the Lambda service when the event does not carry one
+	if req.Header.Get("X-Amzn-Trace-Id") == "" {
+		if traceHeader := os.Getenv(xrayTraceEnvVar); traceHeader != "" {
+			req.Header.Set("X-Amzn-Trace-Id", traceHeader)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec, nil
+}
+
+// lambdaQuery encodes the query string parameters of an API Gateway REST or ALB event.
+func lambdaQuery(params map[string]string, multiValueParams map[string][]string) string {
+	values := url.Values{}
+	for k, v := range params {
+		values.Set(k, v)
+	}
+	for k, v := range multiValueParams {
+		values[k] = v
+	}
+	return values.Encode()
+}
+
+// lambdaHeaders returns the headers for responses without multi value headers, the values of a header being joined
+// with ", ". Only the last Set-Cookie header is kept, since cookies cannot be joined. The skipped headers are left out.
+func lambdaHeaders(h http.Header, skip ...string) map[string]string {
+	headers := make(map[string]string, len(h))
+	for k, values := range h {
+		switch {
+		case len(values) == 0 || containsFold(skip, k):
+		case http.CanonicalHeaderKey(k) == "Set-Cookie":
+			headers[k] = values[len(values)-1]
+		default:
+			headers[k] = strings.Join(values, ", ")
+		}
+	}
+	return headers
+}
+
+// containsFold reports whether list holds s, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, e := range list {
+		if strings.EqualFold(e, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// lambdaBody returns the recorded body, base64 encoded when it is not text.
+func lambdaBody(rec *httptest.ResponseRecorder) (string, bool) {
+	body, _ := io.ReadAll(rec.Body)
+	contentType := rec.Header().Get("Content-Type")
+	if utf8.Valid(body) && (contentType == "" || strings.HasPrefix(contentType, "text/") || strings.Contains(contentType, "json")) {
+		return string(body), false
+	}
+	return base64.StdEncoding.EncodeToString(body), true
+}
@@ -0,0 +1,149 @@
+package collection
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// lambdaFixture loads an event of the events directory, pointed at path. mutate may change the decoded event.
+func lambdaFixture(t *testing.T, name, path string, mutate func(map[string]interface{})) json.RawMessage {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "events", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event["version"] == "2.0" {
+		event["rawPath"] = path
+	} else {
+		event["path"] = path
+	}
+	if mutate != nil {
+		mutate(event)
+	}
+	if data, err = json.Marshal(event); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestLambdaHandler(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Endpoints = []EndpointConfig{
+		{Path: "/lambda", Latency: LatencyConfig{Distribution: latencyFixed}},
+		{Path: "/lambda-error", ErrorRate: 1, ErrorStatus: http.StatusServiceUnavailable},
+	}
+	ta := newTestApp(t, cfg)
+	handler := ta.LambdaHandler()
+
+	withMultiValueHeaders := func(event map[string]interface{}) {
+		multi := map[string]interface{}{}
+		for k, v := range event["headers"].(map[string]interface{}) {
+			multi[k] = []interface{}{v}
+		}
+		event["multiValueHeaders"] = multi
+	}
+
+	tests := []struct {
+		name       string
+		fixture    string
+		path       string
+		mutate     func(map[string]interface{})
+		wantStatus int
+		// wantMultiValue is whether the response carries multi value headers instead of single value ones
+		wantMultiValue bool
+		// wantTraceID is the trace continued from the X-Amzn-Trace-Id header of the event, if any
+		wantTraceID string
+	}{
+		{"REST API", "apigateway-rest.json", "/lambda", nil, http.StatusOK, true, "6530a4a63c8e0d2d1b2a4f5e6d7c8b9a"},
+		{"HTTP API", "apigateway-http.json", "/lambda", nil, http.StatusOK, false, ""},
+		{"HTTP API error", "apigateway-http.json", "/lambda-error", nil, http.StatusServiceUnavailable, false, ""},
+		// The ALB header only holds the root, without parent there is no trace to continue
+		{"ALB", "alb.json", "/lambda", nil, http.StatusOK, false, ""},
+		{"ALB multi value headers", "alb.json", "/lambda", withMultiValueHeaders, http.StatusOK, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ta.spans.Reset()
+			res, err := handler(context.Background(), lambdaFixture(t, tt.fixture, tt.path, tt.mutate))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var (
+				status  int
+				headers map[string]string
+				multi   map[string][]string
+			)
+			switch res := res.(type) {
+			case events.APIGatewayProxyResponse:
+				status, headers, multi = res.StatusCode, res.Headers, res.MultiValueHeaders
+			case events.APIGatewayV2HTTPResponse:
+				status, headers, multi = res.StatusCode, res.Headers, res.MultiValueHeaders
+			case events.ALBTargetGroupResponse:
+				status, headers, multi = res.StatusCode, res.Headers, res.MultiValueHeaders
+			default:
+				t.Fatalf("unexpected response %T", res)
+			}
+
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			get := func(key string) string { return headers[key] }
+			if tt.wantMultiValue {
+				if len(headers) != 0 {
+					t.Errorf("single value headers %v, want only multi value headers", headers)
+				}
+				get = func(key string) string { return http.Header(multi).Get(key) }
+			} else if len(multi) != 0 {
+				t.Errorf("multi value headers %v, want only single value headers", multi)
+			}
+			if got := get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			for _, key := range []string{"Traceresponse", "X-Amzn-Trace-Id"} {
+				if get(key) == "" {
+					t.Errorf("%s header is missing", key)
+				}
+			}
+
+			var server []trace.SpanContext
+			for _, span := range ta.spans.GetSpans() {
+				if span.SpanKind == trace.SpanKindServer {
+					server = append(server, span.SpanContext)
+				}
+			}
+			if len(server) != 1 {
+				t.Fatalf("recorded %d server spans, want 1", len(server))
+			}
+			if tt.wantTraceID != "" && server[0].TraceID().String() != tt.wantTraceID {
+				t.Errorf("trace ID = %s, want the trace of the event %s", server[0].TraceID(), tt.wantTraceID)
+			}
+		})
+	}
+}
+
+func TestLambdaHeaders(t *testing.T) {
+	h := http.Header{
+		"Content-Type": {"application/json"},
+		"Vary":         {"Accept", "Origin"},
+		"Set-Cookie":   {"a=1", "b=2"},
+	}
+	got := lambdaHeaders(h)
+	if got["Vary"] != "Accept, Origin" || got["Content-Type"] != "application/json" || got["Set-Cookie"] != "b=2" {
+		t.Errorf("lambdaHeaders() = %v", got)
+	}
+	if got := lambdaHeaders(h, "Set-Cookie"); got["Set-Cookie"] != "" {
+		t.Errorf("lambdaHeaders() kept the skipped Set-Cookie: %v", got)
+	}
+}
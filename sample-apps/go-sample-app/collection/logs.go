@@ -0,0 +1,228 @@
+package collection
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// Contains the request logs exported with OTLP/HTTP.
+
+// Log exporters.
+const (
+	logExporterNone  = "none"
+	logExporterSigV4 = "sigv4"
+)
+
+const (
+	maxLogBatch   = 512
+	logFlushEvery = time.Second
+)
+
+// logEmitter batches log records and exports them every second, or as soon as a batch is full.
+type logEmitter struct {
+	poster   *sigv4Poster
+	resource *resourcepb.Resource
+	status   *exportStatus
+
+	mu      sync.Mutex
+	records []*logspb.LogRecord
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// newLogEmitter starts an emitter sending the logs of res to SigV4.LogsEndpoint, by default the CloudWatch Logs OTLP
+// endpoint of the region, in SigV4.LogGroup and SigV4.LogStream.
+func newLogEmitter(cfg SigV4Config, res *resource.Resource, status *exportStatus) (*logEmitter, error) {
+	service := cfg.LogsService
+	if service == "" {
+		service = defaultLogsService
+	}
+	headers := map[string]string{"x-aws-log-group": cfg.LogGroup, "x-aws-log-stream": cfg.LogStream}
+	poster, err := newSigV4Poster(cfg, cfg.LogsEndpoint, service, "logs", "/v1/logs", headers)
+	if err != nil {
+		return nil, err
+	}
+	e := &logEmitter{
+		poster:   poster,
+		resource: &resourcepb.Resource{Attributes: otlpKeyValues(res.Attributes())},
+		status:   status,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go e.run()
+	return e, nil
+}
+
+// run flushes the batch periodically until the emitter is shut down.
+func (e *logEmitter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(logFlushEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.ForceFlush(context.Background()); err != nil {
+				fmt.Println(err)
+			}
+		case <-e.stop:
+			return
+		}
+	}
+}
+
+// emit adds a record to the batch, correlated with the span of ctx.
+func (e *logEmitter) emit(ctx context.Context, severity logspb.SeverityNumber, body string, attrs ...attribute.KeyValue) {
+	now := uint64(time.Now().UnixNano())
+	record := &logspb.LogRecord{
+		TimeUnixNano:         now,
+		ObservedTimeUnixNano: now,
+		SeverityNumber:       severity,
+		SeverityText:         severityText(severity),
+		Body:                 &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: body}},
+		Attributes:           otlpKeyValues(attrs),
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		traceID, spanID := sc.TraceID(), sc.SpanID()
+		record.TraceId = traceID[:]
+		record.SpanId = spanID[:]
+		record.Flags = uint32(sc.TraceFlags())
+	}
+
+	e.mu.Lock()
+	e.records = append(e.records, record)
+	full := len(e.records) >= maxLogBatch
+	e.mu.Unlock()
+	if full {
+		go func() {
+			if err := e.ForceFlush(context.Background()); err != nil {
+				fmt.Println(err)
+			}
+		}()
+	}
+}
+
+// ForceFlush exports the records of the current batch.
+func (e *logEmitter) ForceFlush(ctx context.Context) error {
+	e.mu.Lock()
+	records := e.records
+	e.records = nil
+	e.mu.Unlock()
+	if len(records) == 0 {
+		return nil
+	}
+
+	err := e.poster.post(ctx, &collogspb.ExportLogsServiceRequest{ResourceLogs: []*logspb.ResourceLogs{{
+		Resource: e.resource,
+		ScopeLogs: []*logspb.ScopeLogs{{
+			Scope:      &commonpb.InstrumentationScope{Name: instrumentationName},
+			LogRecords: records,
+		}},
+		SchemaUrl: semconv.SchemaURL,
+	}}})
+	e.status.record(err, len(records))
+	return err
+}
+
+// Shutdown stops the periodic export and exports the remaining records.
+func (e *logEmitter) Shutdown(ctx context.Context) error {
+	close(e.stop)
+	<-e.done
+	return e.ForceFlush(ctx)
+}
+
+// severityText returns the text of the severities used by the request logs.
+func severityText(severity logspb.SeverityNumber) string {
+	switch severity {
+	case logspb.SeverityNumber_SEVERITY_NUMBER_ERROR:
+		return "ERROR"
+	case logspb.SeverityNumber_SEVERITY_NUMBER_WARN:
+		return "WARN"
+	}
+	return "INFO"
+}
+
+// otlpKeyValues converts attributes to their OTLP representation. Slices are converted to arrays.
+func otlpKeyValues(attrs []attribute.KeyValue) []*commonpb.KeyValue {
+	kvs := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		kvs = append(kvs, &commonpb.KeyValue{Key: string(kv.Key), Value: otlpValue(kv.Value.AsInterface())})
+	}
+	return kvs
+}
+
+// otlpValue converts the value of an attribute to its OTLP representation.
+func otlpValue(v interface{}) *commonpb.AnyValue {
+	switch v := v.(type) {
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v}}
+	case int64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v}}
+	case float64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v}}
+	case string:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}
+	}
+	var values []*commonpb.AnyValue
+	switch v := v.(type) {
+	case []bool:
+		for _, e := range v {
+			values = append(values, otlpValue(e))
+		}
+	case []int64:
+		for _, e := range v {
+			values = append(values, otlpValue(e))
+		}
+	case []float64:
+		for _, e := range v {
+			values = append(values, otlpValue(e))
+		}
+	case []string:
+		for _, e := range v {
+			values = append(values, otlpValue(e))
+		}
+	default:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: fmt.Sprint(v)}}
+	}
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+}
+
+// requestLogMiddleware logs every endpoint invocation, correlated with its server span. Server errors are logged as
+// errors and client errors as warnings.
+func (a *App) requestLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sr := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(sr, r)
+		if sr.status == 0 {
+			sr.status = http.StatusOK
+		}
+
+		severity := logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+		switch {
+		case sr.status >= 500:
+			severity = logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+		case sr.status >= 400:
+			severity = logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+		}
+		attrs := httpMethodAttributes(a.semconv, r.Method)
+		attrs = append(attrs, semconvAttributes(a.semconv, semconv.HTTPTarget(r.URL.RequestURI()), urlPathKey.String(r.URL.Path))...)
+		if a.semconv != semconvOld && r.URL.RawQuery != "" {
+			attrs = append(attrs, urlQueryKey.String(r.URL.RawQuery))
+		}
+		attrs = append(attrs, httpStatusCodeAttributes(a.semconv, sr.status)...)
+		attrs = append(attrs, attribute.Float64("http.duration_ms", float64(time.Since(start).Microseconds())/1000))
+		a.logs.emit(r.Context(), severity, fmt.Sprintf("%s %s %d", r.Method, r.URL.Path, sr.status), attrs...)
+	})
+}
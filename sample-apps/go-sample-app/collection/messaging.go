@@ -0,0 +1,399 @@
+package collection
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Contains the asynchronous messaging flow: the /publish endpoint producing messages and the background consumers
+// processing them.
+
+// Queue implementations selected by the Messaging.Queue configuration key.
+const (
+	queueMemory = "memory"
+	queueSqs    = "sqs"
+)
+
+// memoryQueueName is the destination name of the in-process queue.
+const memoryQueueName = "sample-app-queue"
+
+// sqsAllMessageAttributes asks SQS to return every message attribute of the received messages, where the trace context
+// travels. The SDK only defines All for the queue attributes.
+const sqsAllMessageAttributes = "All"
+
+// maxPublishCount bounds the number of messages a single /publish request can produce.
+const maxPublishCount = 1000
+
+// message is a queued message. Carrier holds the trace context of the producer.
+type message struct {
+	ID      string
+	Body    string
+	Carrier propagation.MapCarrier
+	SentAt  time.Time
+	receipt string
+}
+
+// messageQueue is a queue the sample app produces messages to and consumes messages from.
+type messageQueue interface {
+	// system returns the messaging.system of the queue.
+	system() string
+	// name returns the messaging.destination.name of the queue.
+	name() string
+	send(ctx context.Context, msg *message) error
+	// receive waits for messages until ctx is done or a short poll interval elapses.
+	receive(ctx context.Context) ([]*message, error)
+	// ack removes a processed message from the queue.
+	ack(ctx context.Context, msg *message) error
+	depth(ctx context.Context) (int64, error)
+}
+
+// newMessageQueue returns the queue selected by cfg.
+func newMessageQueue(cfg MessagingConfig) (messageQueue, error) {
+	switch cfg.Queue {
+	case queueMemory:
+		capacity := cfg.Capacity
+		if capacity < 1 {
+			capacity = 1
+		}
+		return &memoryQueue{messages: make(chan *message, capacity)}, nil
+	case queueSqs:
+		if cfg.QueueUrl == "" {
+			return nil, fmt.Errorf("Messaging.QueueUrl is required when Messaging.Queue is %s", queueSqs)
+		}
+		awsCfg := aws.NewConfig()
+		if cfg.SqsEndpoint != "" {
+			awsCfg = awsCfg.WithEndpoint(cfg.SqsEndpoint)
+		}
+		s, err := session.NewSession(awsCfg)
+		if err != nil {
+			return nil, err
+		}
+		return &sqsQueue{client: sqs.New(s), url: cfg.QueueUrl}, nil
+	}
+	return nil, fmt.Errorf("unknown queue %q, expected %s or %s", cfg.Queue, queueMemory, queueSqs)
+}
+
+// memoryQueue is an in-process stand-in for a message queue.
+type memoryQueue struct {
+	messages chan *message
+	nextID   int64
+}
+
+func (q *memoryQueue) system() string { return "in_memory" }
+
+func (q *memoryQueue) name() string { return memoryQueueName }
+
+func (q *memoryQueue) send(ctx context.Context, msg *message) error {
+	msg.ID = strconv.FormatInt(atomic.AddInt64(&q.nextID, 1), 10)
+	msg.SentAt = time.Now()
+	select {
+	case q.messages <- msg:
+		return nil
+	default:
+		return fmt.Errorf("queue %s is full", memoryQueueName)
+	}
+}
+
+func (q *memoryQueue) receive(ctx context.Context) ([]*message, error) {
+	select {
+	case <-ctx.Done():
+		return nil, nil
+	case msg := <-q.messages:
+		return []*message{msg}, nil
+	}
+}
+
+func (q *memoryQueue) ack(context.Context, *message) error { return nil }
+
+func (q *memoryQueue) depth(context.Context) (int64, error) {
+	return int64(len(q.messages)), nil
+}
+
+// sqsQueue is an Amazon SQS queue, or an SQS compatible queue such as ElasticMQ when an endpoint is configured. The
+// trace context travels in the message attributes.
+type sqsQueue struct {
+	client *sqs.SQS
+	url    string
+}
+
+func (q *sqsQueue) system() string { return "aws_sqs" }
+
+func (q *sqsQueue) name() string { return path.Base(q.url) }
+
+func (q *sqsQueue) send(ctx context.Context, msg *message) error {
+	attrs := map[string]*sqs.MessageAttributeValue{}
+	for k, v := range msg.Carrier {
+		attrs[k] = &sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(v)}
+	}
+	out, err := q.client.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+		QueueUrl:          aws.String(q.url),
+		MessageBody:       aws.String(msg.Body),
+		MessageAttributes: attrs,
+	})
+	if err != nil {
+		return err
+	}
+	msg.ID = aws.StringValue(out.MessageId)
+	msg.SentAt = time.Now()
+	return nil
+}
+
+func (q *sqsQueue) receive(ctx context.Context) ([]*message, error) {
+	out, err := q.client.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:              aws.String(q.url),
+		MaxNumberOfMessages:   aws.Int64(10),
+		WaitTimeSeconds:       aws.Int64(1),
+		AttributeNames:        []*string{aws.String(sqs.MessageSystemAttributeNameSentTimestamp)},
+		MessageAttributeNames: []*string{aws.String(sqsAllMessageAttributes)},
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	messages := make([]*message, 0, len(out.Messages))
+	for _, m := range out.Messages {
+		msg := &message{
+			ID:      aws.StringValue(m.MessageId),
+			Body:    aws.StringValue(m.Body),
+			Carrier: propagation.MapCarrier{},
+			receipt: aws.StringValue(m.ReceiptHandle),
+		}
+		for k, v := range m.MessageAttributes {
+			msg.Carrier[k] = aws.StringValue(v.StringValue)
+		}
+		if sent, err := strconv.ParseInt(aws.StringValue(m.Attributes[sqs.MessageSystemAttributeNameSentTimestamp]), 10, 64); err == nil {
+			msg.SentAt = time.UnixMilli(sent)
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+func (q *sqsQueue) ack(ctx context.Context, msg *message) error {
+	_, err := q.client.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(q.url),
+		ReceiptHandle: aws.String(msg.receipt),
+	})
+	return err
+}
+
+func (q *sqsQueue) depth(ctx context.Context) (int64, error) {
+	out, err := q.client.GetQueueAttributesWithContext(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(q.url),
+		AttributeNames: []*string{aws.String(sqs.QueueAttributeNameApproximateNumberOfMessages)},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(aws.StringValue(out.Attributes[sqs.QueueAttributeNameApproximateNumberOfMessages]), 10, 64)
+}
+
+// messaging produces and consumes the messages of a queue, and records the queue depth and consumer lag metrics.
+type messaging struct {
+	cfg        MessagingConfig
+	queue      messageQueue
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	meter      metric.Meter
+	labels     func(...attribute.KeyValue) []attribute.KeyValue
+	queueDepth instrument.Int64ObservableGauge
+	lag        instrument.Float64Histogram
+	depthReg   metric.Registration
+}
+
+// newMessaging returns the messaging flow over the queue selected by cfg. nameSuffix is appended to the metric names
+// and labels returns their attributes.
+func newMessaging(tracer trace.Tracer, propagator propagation.TextMapPropagator, mp metric.MeterProvider, cfg MessagingConfig, nameSuffix string,
+	labels func(...attribute.KeyValue) []attribute.KeyValue) (*messaging, error) {
+	queue, err := newMessageQueue(cfg)
+	if err != nil {
+		return nil, err
+	}
+	m := &messaging{cfg: cfg, queue: queue, tracer: tracer, propagator: propagator, meter: mp.Meter(instrumentationName), labels: labels}
+	if m.queueDepth, err = m.meter.Int64ObservableGauge(
+		queueDepth+nameSuffix,
+		instrument.WithDescription("Number of messages waiting in the queue"),
+		instrument.WithUnit("{message}"),
+	); err != nil {
+		return nil, err
+	}
+	if m.lag, err = m.meter.Float64Histogram(
+		consumerLag+nameSuffix,
+		instrument.WithDescription("Time between the publication of a message and the start of its processing"),
+		instrument.WithUnit("ms"),
+	); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// attributes returns the messaging attributes shared by the spans and metrics of the queue.
+func (m *messaging) attributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.MessagingSystem(m.queue.system()),
+		semconv.MessagingDestinationName(m.queue.name()),
+		semconv.MessagingDestinationKindQueue,
+	}
+}
+
+// publish sends a message in a PRODUCER span whose context is injected in the message.
+func (m *messaging) publish(ctx context.Context, body string) error {
+	ctx, span := m.tracer.Start(ctx, m.queue.name()+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(m.attributes()...),
+		trace.WithAttributes(semconv.MessagingOperationPublish, semconv.MessagingMessagePayloadSizeBytes(len(body))),
+	)
+	defer span.End()
+
+	msg := &message{Body: body, Carrier: propagation.MapCarrier{}}
+	m.propagator.Inject(ctx, msg.Carrier)
+	if err := m.queue.send(ctx, msg); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	span.SetAttributes(semconv.MessagingMessageID(msg.ID))
+	return nil
+}
+
+// start registers the queue depth callback and starts cfg.Consumers consumers, which run until ctx is done.
+func (m *messaging) start(ctx context.Context) error {
+	var err error
+	m.depthReg, err = m.meter.RegisterCallback(
+		// SDK periodically calls this function to collect data.
+		func(ctx context.Context, o metric.Observer) error {
+			depth, err := m.queue.depth(ctx)
+			if err != nil {
+				return err
+			}
+			o.ObserveInt64(m.queueDepth, depth, m.labels(m.attributes()...)...)
+			return nil
+		},
+		m.queueDepth,
+	)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < m.cfg.Consumers; i++ {
+		go m.consume(ctx)
+	}
+	return nil
+}
+
+// close unregisters the queue depth callback. The consumers stop with the context given to start.
+func (m *messaging) close() error {
+	if m.depthReg == nil {
+		return nil
+	}
+	return m.depthReg.Unregister()
+}
+
+// consume processes messages until ctx is done.
+func (m *messaging) consume(ctx context.Context) {
+	for ctx.Err() == nil {
+		messages, err := m.queue.receive(ctx)
+		if err != nil {
+			fmt.Println(err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		for _, msg := range messages {
+			m.process(ctx, msg)
+		}
+	}
+}
+
+// process handles msg in a CONSUMER span. The span starts a new trace linked to the producer span, the baggage of
+// the producer is kept. The consumer lag is only recorded when the queue reported when msg was sent. A message whose
+// processing is interrupted by ctx is not acknowledged.
+func (m *messaging) process(ctx context.Context, msg *message) {
+	producerCtx := m.propagator.Extract(ctx, msg.Carrier)
+
+	ctx, span := m.tracer.Start(producerCtx, m.queue.name()+" process",
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.Link{SpanContext: trace.SpanContextFromContext(producerCtx)}),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(m.attributes()...),
+		trace.WithAttributes(
+			semconv.MessagingOperationProcess,
+			semconv.MessagingMessageID(msg.ID),
+			semconv.MessagingMessagePayloadSizeBytes(len(msg.Body)),
+		),
+	)
+	defer span.End()
+	if !msg.SentAt.IsZero() {
+		m.lag.Record(ctx, float64(time.Since(msg.SentAt).Microseconds())/1000, m.labels(m.attributes()...)...)
+	}
+
+	// Simulated work
+	select {
+	case <-ctx.Done():
+		span.SetStatus(codes.Error, ctx.Err().Error())
+		return
+	case <-time.After(time.Duration(m.cfg.ProcessingTime) * time.Millisecond):
+	}
+
+	if err := m.queue.ack(ctx, msg); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// Publish produces count messages (query parameter, default 1) on the configured queue and generates an Xray Trace
+// ID. The messages are processed asynchronously by the background consumers.
+func (a *App) Publish(w http.ResponseWriter, r *http.Request) {
+	count := 1
+	if v := r.URL.Query().Get("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPublishCount {
+			http.Error(w, fmt.Sprintf("count must be an integer between 1 and %d, got %q", maxPublishCount, v), http.StatusBadRequest)
+			return
+		}
+		count = n
+	}
+	resp, err := a.publish(r.Context(), count)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	a.writeResponse(w, resp)
+}
+
+// publish is the operation behind Publish, shared by the HTTP and gRPC servers.
+func (a *App) publish(ctx context.Context, count int) (response, error) {
+	ctx, span := a.tracer.Start(
+		ctx,
+		"publish",
+		trace.WithAttributes(a.traceLabels...),
+	)
+	defer span.End()
+
+	for i := 0; i < count; i++ {
+		if err := a.messaging.publish(ctx, fmt.Sprintf("message %d of %d", i+1, count)); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return response{}, err
+		}
+	}
+	return a.newResponse(span, nil), nil
+}
@@ -0,0 +1,97 @@
+package collection
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// consumeOne receives a message from the queue of ta and processes it.
+func consumeOne(t *testing.T, ta *testApp) {
+	t.Helper()
+	messages, err := ta.messaging.queue.receive(context.Background())
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("received %d messages, %v", len(messages), err)
+	}
+	ta.messaging.process(context.Background(), messages[0])
+}
+
+// spanOfKind returns the single span of the given kind recorded by ta.
+func spanOfKind(t *testing.T, ta *testApp, kind trace.SpanKind) tracetest.SpanStub {
+	t.Helper()
+	var found []tracetest.SpanStub
+	for _, span := range ta.spans.GetSpans() {
+		if span.SpanKind == kind {
+			found = append(found, span)
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("recorded %d %s spans, want 1", len(found), kind)
+	}
+	return found[0]
+}
+
+func TestMessaging(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Messaging.ProcessingTime = 0
+	ta := newTestApp(t, cfg)
+
+	lagCount := func() uint64 {
+		dp, _ := histogramPoint(t, ta.metrics(t)[consumerLag], ta.rqmc.Labels(ta.messaging.attributes()...)...)
+		return dp.Count
+	}
+
+	t.Run("link", func(t *testing.T) {
+		ta.spans.Reset()
+		if err := ta.messaging.publish(context.Background(), "hello"); err != nil {
+			t.Fatal(err)
+		}
+		consumeOne(t, ta)
+
+		producer := spanOfKind(t, ta, trace.SpanKindProducer)
+		consumer := spanOfKind(t, ta, trace.SpanKindConsumer)
+		if consumer.SpanContext.TraceID() == producer.SpanContext.TraceID() {
+			t.Error("the consumer span continues the trace of the producer, want a new trace")
+		}
+		if len(consumer.Links) != 1 || consumer.Links[0].SpanContext.SpanID() != producer.SpanContext.SpanID() ||
+			consumer.Links[0].SpanContext.TraceID() != producer.SpanContext.TraceID() {
+			t.Errorf("consumer links = %v, want the producer span %v", consumer.Links, producer.SpanContext)
+		}
+		if got, want := spanAttr(consumer, "messaging.message.id").AsString(), spanAttr(producer, "messaging.message.id").AsString(); got != want {
+			t.Errorf("consumer messaging.message.id = %q, want %q", got, want)
+		}
+		if n := lagCount(); n != 1 {
+			t.Errorf("recorded %d lags, want 1", n)
+		}
+	})
+
+	t.Run("unknown send time", func(t *testing.T) {
+		before := lagCount()
+		ta.messaging.process(context.Background(), &message{ID: "1", Body: "hello", Carrier: propagation.MapCarrier{}})
+		if n := lagCount(); n != before {
+			t.Errorf("recorded a lag for a message without a send time")
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ta.messaging.cfg.ProcessingTime = int64(time.Hour / time.Millisecond)
+		defer func() { ta.messaging.cfg.ProcessingTime = 0 }()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		done := make(chan struct{})
+		go func() {
+			ta.messaging.process(ctx, &message{ID: "1", Body: "hello", Carrier: propagation.MapCarrier{}})
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("processing did not stop with its context")
+		}
+	})
+}
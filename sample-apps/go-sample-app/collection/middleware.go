@@ -0,0 +1,137 @@
+package collection
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Contains the middlewares applied to every sample app endpoint.
+
+// statusRecorder captures the status code and the number of body bytes written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	sr.status = code
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	n, err := sr.ResponseWriter.Write(b)
+	sr.bytes += int64(n)
+	return n, err
+}
+
+// traceResponseWriter adds the trace context headers to the response right before the header is written, so that
+// Server-Timing can report the time spent in the handler.
+type traceResponseWriter struct {
+	http.ResponseWriter
+	sc          trace.SpanContext
+	start       time.Time
+	wroteHeader bool
+}
+
+func (tw *traceResponseWriter) WriteHeader(code int) {
+	if !tw.wroteHeader {
+		tw.wroteHeader = true
+		setTraceResponseHeaders(tw.Header(), tw.sc, time.Since(tw.start))
+	}
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *traceResponseWriter) Write(b []byte) (int, error) {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+// setTraceResponseHeaders sets the W3C traceresponse, X-Amzn-Trace-Id and Server-Timing headers for the server span.
+func setTraceResponseHeaders(h http.Header, sc trace.SpanContext, elapsed time.Duration) {
+	traceparent := fmt.Sprintf("00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags())
+	sampled := 0
+	if sc.IsSampled() {
+		sampled = 1
+	}
+	h.Set("traceresponse", traceparent)
+	h.Set("X-Amzn-Trace-Id", fmt.Sprintf("Root=%s;Parent=%s;Sampled=%d", xrayTraceID(sc.TraceID()), sc.SpanID(), sampled))
+	h.Add("Server-Timing", fmt.Sprintf(`traceparent;desc="%s"`, traceparent))
+	h.Add("Server-Timing", fmt.Sprintf("app;dur=%.3f", float64(elapsed.Microseconds())/1000))
+	h.Add("Access-Control-Expose-Headers", "traceresponse, X-Amzn-Trace-Id, Server-Timing")
+}
+
+// traceResponseMiddleware returns the trace context of the server span in the response headers, so browsers and load
+// testing tools can correlate any response with its trace.
+func (a *App) traceResponseMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sc := trace.SpanContextFromContext(r.Context())
+		if !a.cfg.TraceResponseHeaders || !sc.IsValid() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tw := &traceResponseWriter{ResponseWriter: w, sc: sc, start: time.Now()}
+		next.ServeHTTP(tw, r)
+		if !tw.wroteHeader {
+			tw.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+// requestMetricsMiddleware records the request based metrics for every endpoint invocation: the request count, the
+// bytes of the response body and the latency of the handler. The route, method and status code attributes and the
+// allowed baggage entries are added when enabled in the configuration.
+func (a *App) requestMetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sr := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(sr, r)
+		latency := time.Since(start)
+		if sr.status == 0 {
+			sr.status = http.StatusOK
+		}
+
+		attrs := a.requestAttributes(r, sr.status)
+
+		// Request based metrics provided by rqmc
+		a.rqmc.AddApiRequest(attrs...)
+		a.rqmc.UpdateTotalBytesSent(r.Context(), sr.bytes, attrs...)
+		a.rqmc.UpdateLatencyTime(r.Context(), latency, attrs...)
+	})
+}
+
+// requestAttributes returns the optional request based metric attributes of a request.
+func (a *App) requestAttributes(r *http.Request, status int) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	opts := a.cfg.RequestMetricAttributes
+	if opts.Route {
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if tmpl, err := current.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+		attrs = append(attrs, semconv.HTTPRoute(route))
+	}
+	if opts.Method {
+		attrs = append(attrs, httpMethodAttributes(a.semconv, r.Method)...)
+	}
+	if opts.StatusCode {
+		attrs = append(attrs, httpStatusCodeAttributes(a.semconv, status)...)
+	}
+	if a.cfg.Baggage.MetricAttributes {
+		attrs = append(attrs, baggageAttributes(r.Context(), a.cfg.Baggage.AllowList)...)
+	}
+	return attrs
+}
@@ -0,0 +1,36 @@
+package collection
+
+import (
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestRequestMetricsMiddleware(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Endpoints = []EndpointConfig{{Path: "/slow", Latency: LatencyConfig{Distribution: latencyFixed, Mean: 50}, ResponseSize: 2048}}
+	ta := newTestApp(t, cfg)
+
+	rec := ta.serve(t, http.MethodGet, "/slow")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /slow = %d, want 200", rec.Code)
+	}
+	metrics := ta.metrics(t)
+
+	sent, ok := metrics[totalBytesSent].Data.(metricdata.Sum[int64])
+	if !ok || len(sent.DataPoints) != 1 {
+		t.Fatalf("%s has no single data point: %+v", totalBytesSent, metrics[totalBytesSent].Data)
+	}
+	if got, want := sent.DataPoints[0].Value, int64(rec.Body.Len()); got != want {
+		t.Errorf("%s = %d, want the %d bytes of the response body", totalBytesSent, got, want)
+	}
+
+	latency, ok := metrics[latencyTime].Data.(metricdata.Histogram)
+	if !ok || len(latency.DataPoints) != 1 || latency.DataPoints[0].Count != 1 {
+		t.Fatalf("%s has no single data point: %+v", latencyTime, metrics[latencyTime].Data)
+	}
+	if got := latency.DataPoints[0].Sum; got < 50 || got > 1000 {
+		t.Errorf("%s = %v ms, want the 50 ms latency of the endpoint", latencyTime, got)
+	}
+}
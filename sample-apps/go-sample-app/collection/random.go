@@ -0,0 +1,85 @@
+package collection
+
+import (
+	"context"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Contains the random source shared by every generator of an App.
+
+// lockedSource is a rand.Source64 which is safe for concurrent use, so a single seeded source can feed the metric
+// callbacks, the request handlers and the background goroutines.
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
+
+// newRand returns a random generator, safe for concurrent use, seeded with seed or with the wall clock when seed is 0.
+func newRand(seed int64) *rand.Rand {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return rand.New(&lockedSource{src: rand.NewSource(seed).(rand.Source64)})
+}
+
+// newIDGenerator returns the X-Ray ID generator of the spans. With a random generator, the IDs are drawn from their
+// own source seeded from rnd, so that the same sequence of spans gets the same IDs whatever the other generators draw
+// afterwards. Without one, the IDs are random.
+func newIDGenerator(rnd *rand.Rand) sdktrace.IDGenerator {
+	if rnd == nil {
+		return xray.NewIDGenerator()
+	}
+	return &seededIDGenerator{rand: rand.New(rand.NewSource(rnd.Int63())), now: time.Now}
+}
+
+// seededIDGenerator generates X-Ray trace IDs, which start with the epoch seconds of the trace, and span IDs from a
+// seeded source. Only the random part of the trace IDs is reproducible.
+type seededIDGenerator struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+	now  func() time.Time
+}
+
+func (g *seededIDGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	tid := trace.TraceID{}
+	binary.BigEndian.PutUint32(tid[:4], uint32(g.now().Unix()))
+	_, _ = g.rand.Read(tid[4:])
+	sid := trace.SpanID{}
+	_, _ = g.rand.Read(sid[:])
+	return tid, sid
+}
+
+func (g *seededIDGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	sid := trace.SpanID{}
+	_, _ = g.rand.Read(sid[:])
+	return sid
+}
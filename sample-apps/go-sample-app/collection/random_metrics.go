@@ -6,29 +6,35 @@ import (
 	"math/rand"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/metric/instrument"
 )
 
-var (
-	threadCount int64 = 0
-	threadsBool       = true
-)
-
-// randomMetricCollector contains all the random based metric instruments.
-type randomMetricCollector struct {
+// RandomMetricCollector contains all the random based metric instruments.
+type RandomMetricCollector struct {
 	timeAlive     instrument.Int64Counter
 	cpuUsage      instrument.Int64ObservableGauge
 	totalHeapSize instrument.Int64ObservableUpDownCounter
 	threadsActive instrument.Int64UpDownCounter
 	meter         metric.Meter
+	nameSuffix    string
+	labels        []attribute.KeyValue
+	rand          *rand.Rand
+	threadCount   int64
+	threadsBool   bool
 }
 
 // NewRandomMetricCollector returns a new type struct that holds and registers the 4 random based metric instruments used in the Go-Sample-App;
-// HeapSize, ThreadsActive, TimeAlive, CpuUsage
-func NewRandomMetricCollector(mp metric.MeterProvider) randomMetricCollector {
-	rmc := randomMetricCollector{}
-	rmc.meter = mp.Meter("github.com/aws-otel-commnunity/sample-apps/go-sample-app/collection")
+// HeapSize, ThreadsActive, TimeAlive, CpuUsage. rnd draws the metric values and must be safe for concurrent use, nil
+// draws them from a generator seeded with the clock. nameSuffix is appended to every metric name and attrs are added
+// to the common attributes.
+func NewRandomMetricCollector(mp metric.MeterProvider, rnd *rand.Rand, nameSuffix string, attrs ...attribute.KeyValue) *RandomMetricCollector {
+	if rnd == nil {
+		rnd = newRand(0)
+	}
+	rmc := &RandomMetricCollector{nameSuffix: nameSuffix, labels: metricLabels(randomMetricCommonLabels, attrs), rand: rnd, threadsBool: true}
+	rmc.meter = mp.Meter(instrumentationName)
 	rmc.registerHeapSize()
 	rmc.registerThreadsActive()
 	rmc.registerTimeAlive()
@@ -37,9 +43,9 @@ func NewRandomMetricCollector(mp metric.MeterProvider) randomMetricCollector {
 }
 
 // registerTimeAlive registers a Synchronous Counter called TimeAlive.
-func (rmc *randomMetricCollector) registerTimeAlive() {
+func (rmc *RandomMetricCollector) registerTimeAlive() {
 	timeAliveMetric, err := rmc.meter.Int64Counter(
-		timeAlive+testingId,
+		timeAlive+rmc.nameSuffix,
 		instrument.WithDescription("Total amount of time that the application has been alive"),
 		instrument.WithUnit("ms"),
 	)
@@ -50,9 +56,9 @@ func (rmc *randomMetricCollector) registerTimeAlive() {
 }
 
 // registerCpuUsage registers an Asynchronous Gauge called CpuUsage.
-func (rmc *randomMetricCollector) registerCpuUsage() {
+func (rmc *RandomMetricCollector) registerCpuUsage() {
 	cpuUsageMetric, err := rmc.meter.Int64ObservableGauge(
-		cpuUsage+testingId,
+		cpuUsage+rmc.nameSuffix,
 		instrument.WithDescription("Cpu usage percent"),
 		instrument.WithUnit("1"),
 	)
@@ -64,9 +70,9 @@ func (rmc *randomMetricCollector) registerCpuUsage() {
 }
 
 // registerHeapSize registers an Asynchronous UpDownCounter called HeapSize.
-func (rmc *randomMetricCollector) registerHeapSize() {
+func (rmc *RandomMetricCollector) registerHeapSize() {
 	totalHeapSizeMetric, err := rmc.meter.Int64ObservableUpDownCounter(
-		totalHeapSize+testingId,
+		totalHeapSize+rmc.nameSuffix,
 		instrument.WithDescription("The current total heap size"),
 		instrument.WithUnit("By"),
 	)
@@ -78,9 +84,9 @@ func (rmc *randomMetricCollector) registerHeapSize() {
 }
 
 // registerThreadsActive registers a Synchronous UpDownCounter called ThreadsActive.
-func (rmc *randomMetricCollector) registerThreadsActive() {
+func (rmc *RandomMetricCollector) registerThreadsActive() {
 	threadsActiveMetric, err := rmc.meter.Int64UpDownCounter(
-		threadsActive+testingId,
+		threadsActive+rmc.nameSuffix,
 		instrument.WithUnit("1"),
 		instrument.WithDescription("The total amount of threads active"),
 	)
@@ -92,77 +98,84 @@ func (rmc *randomMetricCollector) registerThreadsActive() {
 
 // UpdateMetricsClient generates new metric values for Synchronous instruments every TimeInterval and
 // Asynchronous instruments every CollectPeriod configured by the controller.
-func (rmc *randomMetricCollector) RegisterMetricsClient(ctx context.Context, cfg Config) {
+// The Synchronous updates stop when ctx is done.
+func (rmc *RandomMetricCollector) RegisterMetricsClient(ctx context.Context, cfg Config) error {
 	go func() {
+		ticker := time.NewTicker(time.Second * time.Duration(cfg.TimeInterval))
+		defer ticker.Stop()
 		for {
 			rmc.updateTimeAlive(ctx, cfg)
 			rmc.updateThreadsActive(ctx, cfg)
-			time.Sleep(time.Second * time.Duration(cfg.TimeInterval))
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
 		}
 	}()
-	rmc.updateCpuUsage(ctx, cfg)
-	rmc.updateTotalHeapSize(ctx, cfg)
+	if err := rmc.updateCpuUsage(ctx, cfg); err != nil {
+		return err
+	}
+	return rmc.updateTotalHeapSize(ctx, cfg)
 }
 
 // updateTimeAlive updates TimeAlive by TimeAliveIncrementer increments.
-func (rmc *randomMetricCollector) updateTimeAlive(ctx context.Context, cfg Config) {
-	rmc.timeAlive.Add(ctx, cfg.TimeAliveIncrementer*1000, randomMetricCommonLabels...) // in millisconds
+func (rmc *RandomMetricCollector) updateTimeAlive(ctx context.Context, cfg Config) {
+	rmc.timeAlive.Add(ctx, cfg.TimeAliveIncrementer*1000, rmc.labels...) // in millisconds
 }
 
 // updateCpuUsage updates CpuUsage by a value between 0 and CpuUsageUpperBound every SDK call.
-func (rmc *randomMetricCollector) updateCpuUsage(ctx context.Context, cfg Config) {
+func (rmc *RandomMetricCollector) updateCpuUsage(ctx context.Context, cfg Config) error {
 	min := 0
 	max := int(cfg.CpuUsageUpperBound)
-	if _, err := rmc.meter.RegisterCallback(
+	_, err := rmc.meter.RegisterCallback(
 		// SDK periodically calls this function to collect data.
 		func(ctx context.Context, o metric.Observer) error {
-			cpuUsage := int64(rand.Intn(max-min) + min)
-			o.ObserveInt64(rmc.cpuUsage, cpuUsage, randomMetricCommonLabels...)
+			cpuUsage := int64(rmc.rand.Intn(max-min) + min)
+			o.ObserveInt64(rmc.cpuUsage, cpuUsage, rmc.labels...)
 
 			return nil
 		},
 		rmc.cpuUsage,
-	); err != nil {
-		panic(err)
-	}
+	)
+	return err
 }
 
 // updateTotalHeapSize updates HeapSize by a value between 0 and TotalHeapSizeUpperBound every SDK call.
-func (rmc *randomMetricCollector) updateTotalHeapSize(ctx context.Context, cfg Config) {
+func (rmc *RandomMetricCollector) updateTotalHeapSize(ctx context.Context, cfg Config) error {
 	min := 0
 	max := int(cfg.TotalHeapSizeUpperBound)
-	if _, err := rmc.meter.RegisterCallback(
+	_, err := rmc.meter.RegisterCallback(
 		// SDK periodically calls this function to collect data.
 		func(ctx context.Context, o metric.Observer) error {
-			totalHeapSize := int64(rand.Intn(max-min) + min)
-			o.ObserveInt64(rmc.totalHeapSize, totalHeapSize, randomMetricCommonLabels...)
+			totalHeapSize := int64(rmc.rand.Intn(max-min) + min)
+			o.ObserveInt64(rmc.totalHeapSize, totalHeapSize, rmc.labels...)
 
 			return nil
 		},
 		rmc.totalHeapSize,
-	); err != nil {
-		panic(err)
-	}
+	)
+	return err
 }
 
 // updateThreadsActive updates ThreadsActive by a value between 0 and 10 in increments or decrements of 1 based on previous value.
-func (rmc *randomMetricCollector) updateThreadsActive(ctx context.Context, cfg Config) {
-	if threadsBool {
-		if threadCount < int64(cfg.ThreadsActiveUpperBound) {
-			rmc.threadsActive.Add(ctx, 1, randomMetricCommonLabels...)
-			threadCount++
+func (rmc *RandomMetricCollector) updateThreadsActive(ctx context.Context, cfg Config) {
+	if rmc.threadsBool {
+		if rmc.threadCount < int64(cfg.ThreadsActiveUpperBound) {
+			rmc.threadsActive.Add(ctx, 1, rmc.labels...)
+			rmc.threadCount++
 		} else {
-			threadsBool = false
-			threadCount--
+			rmc.threadsBool = false
+			rmc.threadCount--
 		}
 
 	} else {
-		if threadCount > 0 {
-			rmc.threadsActive.Add(ctx, -1, randomMetricCommonLabels...)
-			threadCount--
+		if rmc.threadCount > 0 {
+			rmc.threadsActive.Add(ctx, -1, rmc.labels...)
+			rmc.threadCount--
 		} else {
-			threadsBool = true
-			threadCount++
+			rmc.threadsBool = true
+			rmc.threadCount++
 		}
 	}
 }
@@ -0,0 +1,75 @@
+package collection
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// seededValues returns values drawn from the random generator and the ID generator of an App seeded with seed and
+// configured with opts.
+func seededValues(t *testing.T, seed int64, opts ...Option) ([]int64, []trace.TraceID, []trace.SpanID) {
+	t.Helper()
+	cfg := testConfig(t)
+	cfg.Seed = seed
+	ta := newTestApp(t, cfg, opts...)
+
+	var values []int64
+	for i := 0; i < 5; i++ {
+		values = append(values, ta.rand.Int63())
+	}
+	idg := ta.ids
+	idg.(*seededIDGenerator).now = func() time.Time { return time.Unix(1700000000, 0) }
+	var traceIDs []trace.TraceID
+	var spanIDs []trace.SpanID
+	for i := 0; i < 3; i++ {
+		tid, sid := idg.NewIDs(context.Background())
+		traceIDs = append(traceIDs, tid)
+		spanIDs = append(spanIDs, sid, idg.NewSpanID(context.Background(), tid))
+	}
+	return values, traceIDs, spanIDs
+}
+
+func TestSeed(t *testing.T) {
+	values, traceIDs, spanIDs := seededValues(t, 42)
+	againValues, againTraceIDs, againSpanIDs := seededValues(t, 42)
+	for i := range values {
+		if values[i] != againValues[i] {
+			t.Errorf("value %d = %d then %d with the same seed", i, values[i], againValues[i])
+		}
+	}
+	for i := range traceIDs {
+		if traceIDs[i] != againTraceIDs[i] {
+			t.Errorf("trace ID %d = %s then %s with the same seed", i, traceIDs[i], againTraceIDs[i])
+		}
+		if got := traceIDs[i].String()[:8]; got != "6553f100" {
+			t.Errorf("trace ID %s does not start with the epoch seconds 6553f100", traceIDs[i])
+		}
+	}
+	for i := range spanIDs {
+		if spanIDs[i] != againSpanIDs[i] {
+			t.Errorf("span ID %d = %s then %s with the same seed", i, spanIDs[i], againSpanIDs[i])
+		}
+	}
+
+	otherValues, otherTraceIDs, _ := seededValues(t, 43)
+	if otherValues[0] == values[0] || otherTraceIDs[0] == traceIDs[0] {
+		t.Errorf("seeds 42 and 43 generated the same values")
+	}
+}
+
+func TestWithRand(t *testing.T) {
+	values, traceIDs, spanIDs := seededValues(t, 0, WithRand(newRand(7)))
+	againValues, againTraceIDs, againSpanIDs := seededValues(t, 0, WithRand(newRand(7)))
+	if values[0] != againValues[0] || traceIDs[0] != againTraceIDs[0] || spanIDs[0] != againSpanIDs[0] {
+		t.Errorf("two Apps with the same injected generator drew %d, %s, %s then %d, %s, %s",
+			values[0], traceIDs[0], spanIDs[0], againValues[0], againTraceIDs[0], againSpanIDs[0])
+	}
+
+	ta := newTestApp(t, testConfig(t))
+	if _, ok := ta.ids.(*seededIDGenerator); ok {
+		t.Error("an App without a seed or an injected generator has reproducible IDs")
+	}
+}
@@ -3,39 +3,72 @@ package collection
 import (
 	"context"
 	"fmt"
-	"math/rand"
-	"sync/atomic"
+	"sync"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/metric/instrument"
 )
 
-// requestBasedMetricCollector contains all the request based metric instruments.
-type requestBasedMetricCollector struct {
+// RequestBasedMetricCollector contains all the request based metric instruments.
+type RequestBasedMetricCollector struct {
 	totalBytesSent   instrument.Int64Counter
 	totalApiRequests instrument.Int64ObservableCounter
 	latencyTime      instrument.Int64Histogram
-	config           Config
 	meter            metric.Meter
-	counter          int64
+	nameSuffix       string
+	labels           []attribute.KeyValue
+
+	// requests counts the API requests per attribute set, guarded by mu
+	mu       sync.Mutex
+	requests map[attribute.Distinct]*requestCount
 }
 
-// AddApiRequest adds 1 to the rqmc counter
-func (rqmc *requestBasedMetricCollector) AddApiRequest() {
-	atomic.AddInt64(&rqmc.counter, 1)
+// requestCount is the number of API requests made with an attribute set.
+type requestCount struct {
+	attrs []attribute.KeyValue
+	count int64
 }
 
-// GetApiRequest gets the rqmc counter
-func (rqmc *requestBasedMetricCollector) GetApiRequest() int {
-	return int(atomic.LoadInt64(&rqmc.counter))
+// AddApiRequest adds 1 to the request count of attrs, which are added to the common attributes of the request based
+// metrics.
+func (rqmc *RequestBasedMetricCollector) AddApiRequest(attrs ...attribute.KeyValue) {
+	labels := rqmc.Labels(attrs...)
+	set := attribute.NewSet(labels...)
+	key := set.Equivalent()
+	rqmc.mu.Lock()
+	defer rqmc.mu.Unlock()
+	rc, ok := rqmc.requests[key]
+	if !ok {
+		rc = &requestCount{attrs: labels}
+		rqmc.requests[key] = rc
+	}
+	rc.count++
+}
+
+// GetApiRequest returns the number of API requests made with any attributes.
+func (rqmc *RequestBasedMetricCollector) GetApiRequest() int {
+	rqmc.mu.Lock()
+	defer rqmc.mu.Unlock()
+	var total int64
+	for _, rc := range rqmc.requests {
+		total += rc.count
+	}
+	return int(total)
 }
 
 // NewRequestBasedMetricCollector returns a new type struct that holds and registers the 3 request based metric instruments used in the Go-Sample-App;
-// TotalBytesSent, TotalRequests, LatencyTime
-func NewRequestBasedMetricCollector(ctx context.Context, cfg Config, mp metric.MeterProvider) requestBasedMetricCollector {
+// TotalBytesSent, TotalRequests, LatencyTime. nameSuffix is appended to every metric name and attrs are added to the
+// common attributes.
+func NewRequestBasedMetricCollector(mp metric.MeterProvider, nameSuffix string, attrs ...attribute.KeyValue) *RequestBasedMetricCollector {
 
-	rqmc := requestBasedMetricCollector{config: cfg}
-	rqmc.meter = mp.Meter("github.com/aws-otel-commnunity/sample-apps/go-sample-app/collection")
+	rqmc := &RequestBasedMetricCollector{
+		nameSuffix: nameSuffix,
+		labels:     metricLabels(requestMetricCommonLabels, attrs),
+		requests:   map[attribute.Distinct]*requestCount{},
+	}
+	rqmc.meter = mp.Meter(instrumentationName)
 	rqmc.registerTotalBytesSent()
 	rqmc.registerTotalRequests()
 	rqmc.registerLatencyTime()
@@ -43,9 +76,9 @@ func NewRequestBasedMetricCollector(ctx context.Context, cfg Config, mp metric.M
 }
 
 // registerTotalBytesSent registers a Synchronous counter called TotalBytesSent.
-func (rqmc *requestBasedMetricCollector) registerTotalBytesSent() {
+func (rqmc *RequestBasedMetricCollector) registerTotalBytesSent() {
 	totalBytesSentMetric, err := rqmc.meter.Int64Counter(
-		totalBytesSent+testingId,
+		totalBytesSent+rqmc.nameSuffix,
 		instrument.WithDescription("Keeps a sum of the total amount of bytes sent while the application is alive"),
 		instrument.WithUnit("By"),
 	)
@@ -56,9 +89,9 @@ func (rqmc *requestBasedMetricCollector) registerTotalBytesSent() {
 }
 
 // registerTotalRequests registers an Asynchronous counter called TotalApiRequests.
-func (rqmc *requestBasedMetricCollector) registerTotalRequests() {
+func (rqmc *RequestBasedMetricCollector) registerTotalRequests() {
 	totalApiRequestsMetric, err := rqmc.meter.Int64ObservableCounter(
-		totalApiRequests+testingId,
+		totalApiRequests+rqmc.nameSuffix,
 		instrument.WithDescription("Increments by one every time a sampleapp endpoint is used"),
 		instrument.WithUnit("1"),
 	)
@@ -69,9 +102,9 @@ func (rqmc *requestBasedMetricCollector) registerTotalRequests() {
 }
 
 // registerLatencyTime registers a Synchronous histogram called LatencyTime.
-func (rqmc *requestBasedMetricCollector) registerLatencyTime() {
+func (rqmc *RequestBasedMetricCollector) registerLatencyTime() {
 	latencyTimeMetric, err := rqmc.meter.Int64Histogram(
-		latencyTime+testingId,
+		latencyTime+rqmc.nameSuffix,
 		instrument.WithDescription("Measures latency time in buckets of 100 300 and 500"),
 		instrument.WithUnit("ms"),
 	)
@@ -82,30 +115,45 @@ func (rqmc *requestBasedMetricCollector) registerLatencyTime() {
 }
 
 // StartTotalRequestCallBack starts the callback for the TotalApiRequests.
-func (rqmc *requestBasedMetricCollector) StartTotalRequestCallback() {
-	if _, err := rqmc.meter.RegisterCallback(
+func (rqmc *RequestBasedMetricCollector) StartTotalRequestCallback() error {
+	_, err := rqmc.meter.RegisterCallback(
 		// SDK periodically calls this function to collect data.
 		func(ctx context.Context, o metric.Observer) error {
-			o.ObserveInt64(rqmc.totalApiRequests, int64(rqmc.GetApiRequest()), requestMetricCommonLabels...)
+			rqmc.mu.Lock()
+			defer rqmc.mu.Unlock()
+			for _, rc := range rqmc.requests {
+				o.ObserveInt64(rqmc.totalApiRequests, rc.count, rc.attrs...)
+			}
 
 			return nil
 		},
 		rqmc.totalApiRequests,
-	); err != nil {
-		panic(err)
-	}
+	)
+	return err
+}
+
+// UpdateTotalBytesSent adds the bytes of a response body to TotalBytesSent.
+func (rqmc *RequestBasedMetricCollector) UpdateTotalBytesSent(ctx context.Context, bytes int64, attrs ...attribute.KeyValue) {
+	rqmc.totalBytesSent.Add(ctx, bytes, rqmc.Labels(attrs...)...)
 }
 
-// UpdateTotalBytesSent updates TotalBytesSent with a value between 0 and 1024
-func (rqmc *requestBasedMetricCollector) UpdateTotalBytesSent(ctx context.Context) {
-	min := 0
-	max := 1024
-	rqmc.totalBytesSent.Add(ctx, int64(rand.Intn(max-min)+min), requestMetricCommonLabels...)
+// UpdateLatencyTime records the latency of a request, in milliseconds, in the LatencyTime histogram.
+func (rqmc *RequestBasedMetricCollector) UpdateLatencyTime(ctx context.Context, latency time.Duration, attrs ...attribute.KeyValue) {
+	rqmc.latencyTime.Record(ctx, latency.Milliseconds(), rqmc.Labels(attrs...)...)
 }
 
-// UpdateLatencyTime updates LatencyTime adds an aditional value between 0 and 512 to the histogram distribution.
-func (rqmc *requestBasedMetricCollector) UpdateLatencyTime(ctx context.Context) {
-	min := 0
-	max := 512
-	rqmc.latencyTime.Record(ctx, int64(rand.Intn(max-min)+min), requestMetricCommonLabels...)
+// Labels returns the common attributes of the request based metrics followed by attrs. The metrics recorded while
+// serving requests, such as the database and messaging metrics, share these attributes.
+func (rqmc *RequestBasedMetricCollector) Labels(attrs ...attribute.KeyValue) []attribute.KeyValue {
+	return metricLabels(rqmc.labels, attrs)
+}
+
+// metricLabels returns the common attributes followed by attrs.
+func metricLabels(common, attrs []attribute.KeyValue) []attribute.KeyValue {
+	if len(attrs) == 0 {
+		return common
+	}
+	labels := make([]attribute.KeyValue, 0, len(common)+len(attrs))
+	labels = append(labels, common...)
+	return append(labels, attrs...)
 }
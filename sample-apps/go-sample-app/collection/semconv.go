@@ -0,0 +1,335 @@
+package collection
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Contains the selection of the HTTP semantic conventions of the spans, the metrics and the request logs.
+
+// Semantic convention modes: the HTTP attributes of semconv v1.17.0 set by the instrumentations, the stable HTTP
+// attributes, or both during a migration.
+const (
+	semconvOld    = "old"
+	semconvStable = "stable"
+	semconvDup    = "dup"
+)
+
+// Stable HTTP attributes, which semconv v1.17.0 does not define.
+const (
+	httpRequestMethodKey      = attribute.Key("http.request.method")
+	httpResponseStatusCodeKey = attribute.Key("http.response.status_code")
+	httpRequestBodySizeKey    = attribute.Key("http.request.body.size")
+	httpResponseBodySizeKey   = attribute.Key("http.response.body.size")
+	urlFullKey                = attribute.Key("url.full")
+	urlSchemeKey              = attribute.Key("url.scheme")
+	urlPathKey                = attribute.Key("url.path")
+	urlQueryKey               = attribute.Key("url.query")
+	serverAddressKey          = attribute.Key("server.address")
+	serverPortKey             = attribute.Key("server.port")
+	clientAddressKey          = attribute.Key("client.address")
+	clientPortKey             = attribute.Key("client.port")
+	networkPeerAddressKey     = attribute.Key("network.peer.address")
+	networkPeerPortKey        = attribute.Key("network.peer.port")
+	networkProtocolVersionKey = attribute.Key("network.protocol.version")
+	userAgentOriginalKey      = attribute.Key("user_agent.original")
+)
+
+// httpHostKey is the Host header set by older instrumentations on server spans.
+const httpHostKey = attribute.Key("http.host")
+
+// stableHTTPKeys maps the HTTP attributes of semconv v1.17.0 to their stable name. http.target and the net.peer.*
+// attributes are converted by stableHTTPAttributes, since they are split or depend on the span kind.
+var stableHTTPKeys = map[attribute.Key]attribute.Key{
+	semconv.HTTPMethodKey:                httpRequestMethodKey,
+	semconv.HTTPStatusCodeKey:            httpResponseStatusCodeKey,
+	semconv.HTTPURLKey:                   urlFullKey,
+	semconv.HTTPSchemeKey:                urlSchemeKey,
+	semconv.HTTPUserAgentKey:             userAgentOriginalKey,
+	semconv.HTTPClientIPKey:              clientAddressKey,
+	semconv.HTTPFlavorKey:                networkProtocolVersionKey,
+	semconv.HTTPRequestContentLengthKey:  httpRequestBodySizeKey,
+	semconv.HTTPResponseContentLengthKey: httpResponseBodySizeKey,
+	semconv.NetSockPeerAddrKey:           networkPeerAddressKey,
+	semconv.NetSockPeerPortKey:           networkPeerPortKey,
+	semconv.NetHostNameKey:               serverAddressKey,
+	semconv.NetHostPortKey:               serverPortKey,
+	httpHostKey:                          serverAddressKey,
+}
+
+// semconvMode returns the configured mode or, when none is configured, the mode selected by
+// OTEL_SEMCONV_STABILITY_OPT_IN like the OpenTelemetry instrumentations: http for stable and http/dup for dup.
+func semconvMode(configured string) string {
+	if configured != "" {
+		return configured
+	}
+	mode := semconvOld
+	for _, value := range strings.Split(os.Getenv("OTEL_SEMCONV_STABILITY_OPT_IN"), ",") {
+		switch strings.TrimSpace(value) {
+		case "http/dup":
+			return semconvDup
+		case "http":
+			mode = semconvStable
+		}
+	}
+	return mode
+}
+
+// httpMethodAttributes returns the request method attributes of mode.
+func httpMethodAttributes(mode, method string) []attribute.KeyValue {
+	return semconvAttributes(mode, semconv.HTTPMethod(method), httpRequestMethodKey.String(method))
+}
+
+// httpStatusCodeAttributes returns the response status code attributes of mode.
+func httpStatusCodeAttributes(mode string, status int) []attribute.KeyValue {
+	return semconvAttributes(mode, semconv.HTTPStatusCode(status), httpResponseStatusCodeKey.Int(status))
+}
+
+// semconvAttributes returns the old attribute, the stable one or both depending on mode.
+func semconvAttributes(mode string, old, stable attribute.KeyValue) []attribute.KeyValue {
+	switch mode {
+	case semconvStable:
+		return []attribute.KeyValue{stable}
+	case semconvDup:
+		return []attribute.KeyValue{old, stable}
+	}
+	return []attribute.KeyValue{old}
+}
+
+// stableHTTPAttributes returns the stable equivalent of the HTTP attributes of semconv v1.17.0 in attrs, and whether
+// attrs holds any of them. An attribute which is already set in its stable form is not converted.
+func stableHTTPAttributes(kind trace.SpanKind, attrs []attribute.KeyValue) ([]attribute.KeyValue, bool) {
+	set := map[attribute.Key]bool{}
+	for _, kv := range attrs {
+		set[kv.Key] = true
+	}
+	var stable []attribute.KeyValue
+	add := func(kv attribute.KeyValue) {
+		if !set[kv.Key] {
+			set[kv.Key] = true
+			stable = append(stable, kv)
+		}
+	}
+	converted := false
+	for _, kv := range attrs {
+		if key, ok := stableHTTPKeys[kv.Key]; ok {
+			converted = true
+			if kv.Key == httpHostKey {
+				// The Host header may hold the port
+				host := kv.Value.AsString()
+				if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.HasSuffix(host, "]") {
+					host = host[:i]
+				}
+				add(key.String(strings.Trim(host, "[]")))
+				continue
+			}
+			add(attribute.KeyValue{Key: key, Value: kv.Value})
+			continue
+		}
+		switch kv.Key {
+		case semconv.HTTPTargetKey:
+			converted = true
+			path, query, found := strings.Cut(kv.Value.AsString(), "?")
+			add(urlPathKey.String(path))
+			if found {
+				add(urlQueryKey.String(query))
+			}
+		case semconv.NetPeerNameKey:
+			converted = true
+			if kind == trace.SpanKindServer {
+				add(clientAddressKey.String(kv.Value.AsString()))
+			} else {
+				add(serverAddressKey.String(kv.Value.AsString()))
+			}
+		case semconv.NetPeerPortKey:
+			converted = true
+			if kind == trace.SpanKindServer {
+				add(clientPortKey.Int64(kv.Value.AsInt64()))
+			} else {
+				add(serverPortKey.Int64(kv.Value.AsInt64()))
+			}
+		}
+	}
+	return stable, converted
+}
+
+// isOldHTTPAttribute reports whether key is an HTTP attribute of semconv v1.17.0 replaced in the stable mode.
+func isOldHTTPAttribute(key attribute.Key) bool {
+	if _, ok := stableHTTPKeys[key]; ok {
+		return true
+	}
+	return key == semconv.HTTPTargetKey || key == semconv.NetPeerNameKey || key == semconv.NetPeerPortKey
+}
+
+// semconvSpanExporter wraps a span exporter and converts the attributes of HTTP spans, the spans with an http.method
+// attribute, to the semantic conventions of mode. The conversion happens on export since the instrumentations set
+// some attributes, like the status code, when the span ends.
+type semconvSpanExporter struct {
+	sdktrace.SpanExporter
+	mode string
+}
+
+func (e semconvSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if e.mode == semconvOld {
+		return e.SpanExporter.ExportSpans(ctx, spans)
+	}
+	converted := make([]sdktrace.ReadOnlySpan, len(spans))
+	for i, span := range spans {
+		converted[i] = semconvSpan(span, e.mode)
+	}
+	return e.SpanExporter.ExportSpans(ctx, converted)
+}
+
+// semconvSpan returns span with its HTTP attributes converted to mode.
+func semconvSpan(span sdktrace.ReadOnlySpan, mode string) sdktrace.ReadOnlySpan {
+	attrs, ok := semconvConvert(mode, span.SpanKind(), span.Attributes())
+	if !ok {
+		return span
+	}
+	return convertedSpan{ReadOnlySpan: span, attributes: attrs}
+}
+
+// semconvConvert returns attrs with their HTTP attributes of semconv v1.17.0 converted to mode, and whether attrs
+// holds any of them.
+func semconvConvert(mode string, kind trace.SpanKind, attrs []attribute.KeyValue) ([]attribute.KeyValue, bool) {
+	stable, ok := stableHTTPAttributes(kind, attrs)
+	if !ok {
+		return attrs, false
+	}
+	var kept []attribute.KeyValue
+	if mode == semconvDup {
+		kept = append(kept, attrs...)
+	} else {
+		for _, kv := range attrs {
+			if !isOldHTTPAttribute(kv.Key) {
+				kept = append(kept, kv)
+			}
+		}
+	}
+	return append(kept, stable...), true
+}
+
+// convertedSpan is a span whose attributes were replaced.
+type convertedSpan struct {
+	sdktrace.ReadOnlySpan
+	attributes []attribute.KeyValue
+}
+
+func (s convertedSpan) Attributes() []attribute.KeyValue {
+	return s.attributes
+}
+
+// withOldHTTPAttributes sets the HTTP attributes of semconv v1.17.0 missing from attrs from their stable equivalent,
+// so that spans converted to the stable mode are read like the others.
+func withOldHTTPAttributes(kind trace.SpanKind, attrs map[attribute.Key]attribute.Value) {
+	setOld := func(old, stable attribute.Key) {
+		if _, ok := attrs[old]; !ok {
+			if v, ok := attrs[stable]; ok {
+				attrs[old] = v
+			}
+		}
+	}
+	for old, stable := range stableHTTPKeys {
+		if old != httpHostKey {
+			setOld(old, stable)
+		}
+	}
+	if kind != trace.SpanKindServer {
+		setOld(semconv.NetPeerNameKey, serverAddressKey)
+		setOld(semconv.NetPeerPortKey, serverPortKey)
+	} else {
+		setOld(semconv.NetPeerNameKey, clientAddressKey)
+		setOld(semconv.NetPeerPortKey, clientPortKey)
+	}
+	if _, ok := attrs[semconv.HTTPTargetKey]; !ok {
+		if path, ok := attrs[urlPathKey]; ok {
+			target := path.AsString()
+			if query := attrs[urlQueryKey].AsString(); query != "" {
+				target += "?" + query
+			}
+			attrs[semconv.HTTPTargetKey] = attribute.StringValue(target)
+		}
+	}
+}
+
+// semconvMetricExporter wraps a metric exporter and converts the data point attributes of the metrics recorded by the
+// instrumentations to the semantic conventions of mode. otelgrpc records rpc.server.duration with the net.sock.peer.*
+// or net.peer.* attributes; otelhttp and otelmux record no metrics for the transport and router used by the App. The
+// metric names are kept, the rpc metrics have no stable name. The request based metrics are recorded in mode already.
+type semconvMetricExporter struct {
+	metric.Exporter
+	mode string
+}
+
+func (e semconvMetricExporter) Export(ctx context.Context, rm metricdata.ResourceMetrics) error {
+	if e.mode == semconvOld {
+		return e.Exporter.Export(ctx, rm)
+	}
+	converted := rm
+	converted.ScopeMetrics = make([]metricdata.ScopeMetrics, len(rm.ScopeMetrics))
+	for i, sm := range rm.ScopeMetrics {
+		converted.ScopeMetrics[i] = sm
+		if !strings.HasPrefix(sm.Scope.Name, "go.opentelemetry.io/contrib/instrumentation/") {
+			continue
+		}
+		converted.ScopeMetrics[i].Metrics = make([]metricdata.Metrics, len(sm.Metrics))
+		for j, m := range sm.Metrics {
+			converted.ScopeMetrics[i].Metrics[j] = semconvMetric(m, e.mode)
+		}
+	}
+	return e.Exporter.Export(ctx, converted)
+}
+
+// semconvMetric returns m with the HTTP attributes of its data points converted to mode. The peer of the server
+// metrics is the client, like on server spans.
+func semconvMetric(m metricdata.Metrics, mode string) metricdata.Metrics {
+	kind := trace.SpanKindClient
+	if strings.Contains(m.Name, ".server.") {
+		kind = trace.SpanKindServer
+	}
+	convert := func(set attribute.Set) attribute.Set {
+		if attrs, ok := semconvConvert(mode, kind, set.ToSlice()); ok {
+			return attribute.NewSet(attrs...)
+		}
+		return set
+	}
+	switch data := m.Data.(type) {
+	case metricdata.Histogram:
+		data.DataPoints = append([]metricdata.HistogramDataPoint(nil), data.DataPoints...)
+		for i := range data.DataPoints {
+			data.DataPoints[i].Attributes = convert(data.DataPoints[i].Attributes)
+		}
+		m.Data = data
+	case metricdata.Sum[int64]:
+		data.DataPoints = semconvDataPoints(data.DataPoints, convert)
+		m.Data = data
+	case metricdata.Sum[float64]:
+		data.DataPoints = semconvDataPoints(data.DataPoints, convert)
+		m.Data = data
+	case metricdata.Gauge[int64]:
+		data.DataPoints = semconvDataPoints(data.DataPoints, convert)
+		m.Data = data
+	case metricdata.Gauge[float64]:
+		data.DataPoints = semconvDataPoints(data.DataPoints, convert)
+		m.Data = data
+	}
+	return m
+}
+
+// semconvDataPoints returns a copy of dps with their attributes converted.
+func semconvDataPoints[N int64 | float64](dps []metricdata.DataPoint[N], convert func(attribute.Set) attribute.Set) []metricdata.DataPoint[N] {
+	converted := make([]metricdata.DataPoint[N], len(dps))
+	for i, dp := range dps {
+		dp.Attributes = convert(dp.Attributes)
+		converted[i] = dp
+	}
+	return converted
+}
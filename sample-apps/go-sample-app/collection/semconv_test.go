@@ -0,0 +1,184 @@
+package collection
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// semconvKeys returns the keys of attrs.
+func semconvKeys(attrs []attribute.KeyValue) map[attribute.Key]bool {
+	keys := map[attribute.Key]bool{}
+	for _, kv := range attrs {
+		keys[kv.Key] = true
+	}
+	return keys
+}
+
+// checkSemconvKeys reports the keys of want missing from got and the keys of unwanted present in got.
+func checkSemconvKeys(t *testing.T, got map[attribute.Key]bool, want, unwanted []attribute.Key) {
+	t.Helper()
+	for _, key := range want {
+		if !got[key] {
+			t.Errorf("%s is missing", key)
+		}
+	}
+	for _, key := range unwanted {
+		if got[key] {
+			t.Errorf("%s is set", key)
+		}
+	}
+}
+
+var (
+	oldServerKeys = []attribute.Key{
+		semconv.HTTPMethodKey, semconv.HTTPTargetKey, semconv.HTTPSchemeKey, semconv.HTTPStatusCodeKey,
+		semconv.NetHostNameKey, semconv.NetSockPeerAddrKey,
+	}
+	stableServerKeys = []attribute.Key{
+		httpRequestMethodKey, urlPathKey, urlQueryKey, urlSchemeKey, httpResponseStatusCodeKey, serverAddressKey,
+		networkPeerAddressKey,
+	}
+	oldClientKeys = []attribute.Key{
+		semconv.HTTPMethodKey, semconv.HTTPURLKey, semconv.HTTPStatusCodeKey, semconv.NetPeerNameKey, semconv.NetPeerPortKey,
+	}
+	stableClientKeys = []attribute.Key{
+		httpRequestMethodKey, urlFullKey, httpResponseStatusCodeKey, serverAddressKey, serverPortKey,
+	}
+)
+
+func TestSemconvSpanExporter(t *testing.T) {
+	tests := []struct {
+		mode                           string
+		wantServer, wantClient         []attribute.Key
+		unwantedServer, unwantedClient []attribute.Key
+	}{
+		{semconvOld, oldServerKeys, oldClientKeys, stableServerKeys, stableClientKeys},
+		{semconvStable, stableServerKeys, stableClientKeys, oldServerKeys, oldClientKeys},
+		{semconvDup, append(oldServerKeys, stableServerKeys...), append(oldClientKeys, stableClientKeys...), nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			spans := tracetest.NewInMemoryExporter()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(semconvSpanExporter{spans, tt.mode}))
+			defer tp.Shutdown(context.Background())
+			tracer := tp.Tracer("test")
+
+			_, server := tracer.Start(context.Background(), "GET /orders", trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+				semconv.HTTPMethod("GET"),
+				semconv.HTTPTarget("/orders?id=42"),
+				semconv.HTTPScheme("http"),
+				semconv.NetHostName("example.com"),
+				semconv.NetSockPeerAddr("10.0.0.1"),
+			))
+			server.SetAttributes(semconv.HTTPStatusCode(200))
+			server.End()
+			_, client := tracer.Start(context.Background(), "HTTP GET", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+				semconv.HTTPMethod("GET"),
+				semconv.HTTPURL("http://downstream:8080/items"),
+				semconv.NetPeerName("downstream"),
+				semconv.NetPeerPort(8080),
+			))
+			client.SetAttributes(semconv.HTTPStatusCode(503))
+			client.End()
+
+			got := spans.GetSpans()
+			if len(got) != 2 {
+				t.Fatalf("exported %d spans, want 2", len(got))
+			}
+			t.Run("server", func(t *testing.T) {
+				checkSemconvKeys(t, semconvKeys(got[0].Attributes), tt.wantServer, tt.unwantedServer)
+			})
+			t.Run("client", func(t *testing.T) {
+				checkSemconvKeys(t, semconvKeys(got[1].Attributes), tt.wantClient, tt.unwantedClient)
+			})
+			if tt.mode != semconvOld {
+				if got := spanAttr(got[1], urlFullKey).AsString(); got != "http://downstream:8080/items" {
+					t.Errorf("url.full = %q", got)
+				}
+				if got := spanAttr(got[1], httpResponseStatusCodeKey).AsInt64(); got != 503 {
+					t.Errorf("http.response.status_code = %d, want 503", got)
+				}
+				if got := spanAttr(got[0], urlQueryKey).AsString(); got != "id=42" {
+					t.Errorf("url.query = %q, want id=42", got)
+				}
+			}
+		})
+	}
+}
+
+// recordingMetricExporter keeps the last exported metrics.
+type recordingMetricExporter struct {
+	rm metricdata.ResourceMetrics
+}
+
+func (e *recordingMetricExporter) Temporality(k sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(k)
+}
+
+func (e *recordingMetricExporter) Aggregation(k sdkmetric.InstrumentKind) aggregation.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(k)
+}
+
+func (e *recordingMetricExporter) Export(ctx context.Context, rm metricdata.ResourceMetrics) error {
+	e.rm = rm
+	return nil
+}
+
+func (e *recordingMetricExporter) ForceFlush(context.Context) error { return nil }
+
+func (e *recordingMetricExporter) Shutdown(context.Context) error { return nil }
+
+func TestSemconvMetricExporter(t *testing.T) {
+	peer := attribute.NewSet(semconv.RPCSystemGRPC, semconv.NetSockPeerAddr("10.0.0.1"), semconv.NetSockPeerPort(50000))
+	rm := metricdata.ResourceMetrics{ScopeMetrics: []metricdata.ScopeMetrics{
+		{
+			Scope: instrumentation.Scope{Name: "go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"},
+			Metrics: []metricdata.Metrics{{Name: "rpc.server.duration", Data: metricdata.Histogram{
+				DataPoints: []metricdata.HistogramDataPoint{{Attributes: peer, Count: 1}},
+			}}},
+		},
+		{
+			Scope: instrumentation.Scope{Name: instrumentationName},
+			Metrics: []metricdata.Metrics{{Name: "total_bytes_sent", Data: metricdata.Sum[int64]{
+				DataPoints: []metricdata.DataPoint[int64]{{Attributes: peer, Value: 1}},
+			}}},
+		},
+	}}
+	tests := []struct {
+		mode             string
+		wanted, unwanted []attribute.Key
+	}{
+		{semconvOld, []attribute.Key{semconv.NetSockPeerAddrKey}, []attribute.Key{networkPeerAddressKey}},
+		{semconvStable, []attribute.Key{networkPeerAddressKey, networkPeerPortKey, semconv.RPCSystemKey}, []attribute.Key{semconv.NetSockPeerAddrKey}},
+		{semconvDup, []attribute.Key{semconv.NetSockPeerAddrKey, networkPeerAddressKey}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			exp := &recordingMetricExporter{}
+			if err := (semconvMetricExporter{exp, tt.mode}).Export(context.Background(), rm); err != nil {
+				t.Fatal(err)
+			}
+			rpc := exp.rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram).DataPoints[0].Attributes
+			checkSemconvKeys(t, semconvKeys(rpc.ToSlice()), tt.wanted, tt.unwanted)
+			// The metrics of the App are recorded in the mode already
+			own := exp.rm.ScopeMetrics[1].Metrics[0].Data.(metricdata.Sum[int64]).DataPoints[0].Attributes
+			if !own.Equals(&peer) {
+				t.Errorf("the attributes of total_bytes_sent were converted: %v", own.ToSlice())
+			}
+		})
+	}
+	// The exported data is a copy
+	if got := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram).DataPoints[0].Attributes; !got.Equals(&peer) {
+		t.Errorf("the input metrics were modified: %v", got.ToSlice())
+	}
+}
@@ -0,0 +1,125 @@
+package collection
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Contains the OTLP/HTTP export signed with SigV4, sending signals directly to the AWS OTLP endpoints.
+
+// Default SigV4 services of the AWS OTLP endpoints.
+const (
+	defaultTracesService = "xray"
+	defaultLogsService   = "logs"
+)
+
+// sigv4Poster posts OTLP/HTTP protobuf requests signed with SigV4 to an endpoint. Its client is not instrumented, so
+// exports do not create spans.
+type sigv4Poster struct {
+	endpoint string
+	service  string
+	region   string
+	headers  map[string]string
+	signer   *v4.Signer
+	client   *http.Client
+}
+
+// newSigV4Poster returns a poster signing with the credentials of the default credentials chain for service. The
+// region defaults to the region of the default session and the endpoint to https://<host>.<region>.amazonaws.com
+// followed by path.
+func newSigV4Poster(cfg SigV4Config, endpoint, service, host, path string, headers map[string]string) (*sigv4Poster, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	region := cfg.Region
+	if region == "" {
+		region = aws.StringValue(sess.Config.Region)
+	}
+	if region == "" {
+		return nil, fmt.Errorf("the SigV4 export needs a region, set SigV4.Region or AWS_REGION")
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.%s.amazonaws.com%s", host, region, path)
+	}
+	return &sigv4Poster{
+		endpoint: endpoint,
+		service:  service,
+		region:   region,
+		headers:  headers,
+		signer:   v4.NewSigner(sess.Config.Credentials),
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// post signs and sends msg. Responses other than 2xx are reported as errors.
+func (p *sigv4Poster) post(ctx context.Context, msg proto.Message) error {
+	body, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range p.headers {
+		req.Header.Set(k, v)
+	}
+	if _, err := p.signer.Sign(req, bytes.NewReader(body), p.service, p.region, time.Now()); err != nil {
+		return fmt.Errorf("signing request: %w", err)
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	msgBody, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("%s responded %s: %s", p.endpoint, res.Status, bytes.TrimSpace(msgBody))
+	}
+	return nil
+}
+
+// sigv4TraceClient is an otlptrace.Client exporting spans with OTLP/HTTP signed with SigV4.
+type sigv4TraceClient struct {
+	poster *sigv4Poster
+}
+
+// newSigV4TraceClient returns a client sending spans to SigV4.TracesEndpoint, by default the X-Ray OTLP endpoint of
+// the region.
+func newSigV4TraceClient(cfg SigV4Config) (*sigv4TraceClient, error) {
+	service := cfg.TracesService
+	if service == "" {
+		service = defaultTracesService
+	}
+	poster, err := newSigV4Poster(cfg, cfg.TracesEndpoint, service, "xray", "/v1/traces", nil)
+	if err != nil {
+		return nil, err
+	}
+	return &sigv4TraceClient{poster: poster}, nil
+}
+
+func (c *sigv4TraceClient) Start(ctx context.Context) error {
+	return nil
+}
+
+func (c *sigv4TraceClient) Stop(ctx context.Context) error {
+	return nil
+}
+
+func (c *sigv4TraceClient) UploadTraces(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	return c.poster.post(ctx, &coltracepb.ExportTraceServiceRequest{ResourceSpans: protoSpans})
+}
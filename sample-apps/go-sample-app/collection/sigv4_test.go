@@ -0,0 +1,215 @@
+package collection
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "us-west-2"
+)
+
+var signedHeadersPattern = regexp.MustCompile(`SignedHeaders=([^,]+)`)
+
+// sigv4StandIn is a local OTLP/HTTP endpoint verifying the SigV4 signature of every request by signing it again
+// with the test credentials.
+type sigv4StandIn struct {
+	*httptest.Server
+	service string
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	problems []string
+}
+
+func newSigV4StandIn(t *testing.T, service string, status int) *sigv4StandIn {
+	t.Helper()
+	s := &sigv4StandIn{service: service}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		problem := s.verify(r, body)
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, body)
+		if problem != "" {
+			s.problems = append(s.problems, problem)
+		}
+		s.mu.Unlock()
+		if problem != "" {
+			http.Error(w, problem, http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// verify signs a copy of r holding its signed headers and compares the signatures.
+func (s *sigv4StandIn) verify(r *http.Request, body []byte) string {
+	auth := r.Header.Get("Authorization")
+	match := signedHeadersPattern.FindStringSubmatch(auth)
+	if match == nil {
+		return "missing SigV4 Authorization header: " + auth
+	}
+	signTime, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return "invalid X-Amz-Date: " + err.Error()
+	}
+	expected, _ := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+	for _, name := range strings.Split(match[1], ";") {
+		if name != "host" {
+			expected.Header[http.CanonicalHeaderKey(name)] = r.Header.Values(name)
+		}
+	}
+	signer := v4.NewSigner(credentials.NewStaticCredentials(testAccessKey, testSecretKey, ""))
+	if _, err := signer.Sign(expected, bytes.NewReader(body), s.service, testRegion, signTime); err != nil {
+		return "signing: " + err.Error()
+	}
+	if got, want := auth, expected.Header.Get("Authorization"); got != want {
+		return "signature mismatch:\n got " + got + "\nwant " + want
+	}
+	return ""
+}
+
+func (s *sigv4StandIn) received(t *testing.T) ([]*http.Request, [][]byte) {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, problem := range s.problems {
+		t.Error(problem)
+	}
+	return s.requests, s.bodies
+}
+
+// setTestCredentials makes the default credentials chain return the test credentials.
+func setTestCredentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", testAccessKey)
+	t.Setenv("AWS_SECRET_ACCESS_KEY", testSecretKey)
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_SDK_LOAD_CONFIG", "")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+}
+
+func TestSigV4TraceExport(t *testing.T) {
+	setTestCredentials(t)
+	standIn := newSigV4StandIn(t, "xray", http.StatusOK)
+
+	client, err := newSigV4TraceClient(SigV4Config{Region: testRegion, TracesEndpoint: standIn.URL + "/v1/traces"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp, err := otlptrace.New(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := resource.NewSchemaless(semconv.ServiceName("sigv4-test"))
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp), sdktrace.WithResource(res))
+	_, span := tp.Tracer("test").Start(context.Background(), "signed span")
+	span.End()
+	if err := tp.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	requests, bodies := standIn.received(t)
+	if len(requests) != 1 {
+		t.Fatalf("received %d requests, want 1", len(requests))
+	}
+	if got := requests[0].URL.Path; got != "/v1/traces" {
+		t.Errorf("path = %s, want /v1/traces", got)
+	}
+	if got := requests[0].Header.Get("Content-Type"); got != "application/x-protobuf" {
+		t.Errorf("Content-Type = %s, want application/x-protobuf", got)
+	}
+	var req coltracepb.ExportTraceServiceRequest
+	if err := proto.Unmarshal(bodies[0], &req); err != nil {
+		t.Fatalf("decoding traces: %v", err)
+	}
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected payload: %v", &req)
+	}
+	if got := req.ResourceSpans[0].ScopeSpans[0].Spans[0].Name; got != "signed span" {
+		t.Errorf("span name = %q, want %q", got, "signed span")
+	}
+}
+
+func TestSigV4LogExport(t *testing.T) {
+	setTestCredentials(t)
+	standIn := newSigV4StandIn(t, "logs", http.StatusOK)
+
+	cfg := SigV4Config{Region: testRegion, LogsEndpoint: standIn.URL + "/v1/logs", LogGroup: "group", LogStream: "stream"}
+	status := &exportStatus{}
+	logs, err := newLogEmitter(cfg, resource.NewSchemaless(semconv.ServiceName("sigv4-test")), status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs.emit(context.Background(), logspb.SeverityNumber_SEVERITY_NUMBER_WARN, "GET /missing 404", semconv.HTTPStatusCode(404))
+	if err := logs.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	requests, bodies := standIn.received(t)
+	if len(requests) != 1 {
+		t.Fatalf("received %d requests, want 1", len(requests))
+	}
+	if got := requests[0].Header.Get("x-aws-log-group"); got != "group" {
+		t.Errorf("x-aws-log-group = %s, want group", got)
+	}
+	if got := requests[0].Header.Get("x-aws-log-stream"); got != "stream" {
+		t.Errorf("x-aws-log-stream = %s, want stream", got)
+	}
+	var req collogspb.ExportLogsServiceRequest
+	if err := proto.Unmarshal(bodies[0], &req); err != nil {
+		t.Fatalf("decoding logs: %v", err)
+	}
+	records := req.ResourceLogs[0].ScopeLogs[0].LogRecords
+	if len(records) != 1 {
+		t.Fatalf("received %d records, want 1", len(records))
+	}
+	if got := records[0].Body.GetStringValue(); got != "GET /missing 404" {
+		t.Errorf("body = %q", got)
+	}
+	if got := records[0].SeverityText; got != "WARN" {
+		t.Errorf("severity = %s, want WARN", got)
+	}
+	if res := status.check(); !res.OK {
+		t.Errorf("export status = %+v, want OK", res)
+	}
+}
+
+func TestSigV4PostRejected(t *testing.T) {
+	setTestCredentials(t)
+	standIn := newSigV4StandIn(t, "xray", http.StatusBadRequest)
+
+	poster, err := newSigV4Poster(SigV4Config{Region: testRegion}, standIn.URL+"/v1/traces", "xray", "xray", "/v1/traces", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := poster.post(context.Background(), &coltracepb.ExportTraceServiceRequest{}); err == nil {
+		t.Error("post succeeded, want the 400 response reported as an error")
+	}
+	standIn.received(t)
+}
@@ -0,0 +1,222 @@
+package collection
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+)
+
+// Instrument kinds supported by the synthetic metric generator.
+const (
+	syntheticCounter                 = "counter"
+	syntheticUpDownCounter           = "updowncounter"
+	syntheticHistogram               = "histogram"
+	syntheticObservableCounter       = "observablecounter"
+	syntheticObservableUpDownCounter = "observableupdowncounter"
+	syntheticObservableGauge         = "observablegauge"
+)
+
+// SyntheticMetricKinds lists every instrument kind the synthetic metric generator can create.
+var SyntheticMetricKinds = []string{
+	syntheticCounter,
+	syntheticUpDownCounter,
+	syntheticHistogram,
+	syntheticObservableCounter,
+	syntheticObservableUpDownCounter,
+	syntheticObservableGauge,
+}
+
+var syntheticMetricCommonLabels = []attribute.KeyValue{
+	attribute.String("signal", "metric"),
+	attribute.String("language", serviceName),
+	attribute.String("metricType", "synthetic"),
+}
+
+// SyntheticMetricCollector generates a configurable number of instruments of every kind, each reporting
+// Cardinality series with AttributeCount attributes, to load test OTLP metric pipelines.
+type SyntheticMetricCollector struct {
+	cfg        SyntheticMetricsConfig
+	meter      metric.Meter
+	nameSuffix string
+	attrSets   [][]attribute.KeyValue
+	rand       *rand.Rand
+
+	counters          []instrument.Int64Counter
+	upDownCounters    []instrument.Int64UpDownCounter
+	histograms        []instrument.Int64Histogram
+	observables       []instrument.Asynchronous
+	observableCounter []instrument.Int64ObservableCounter
+	observableUpDown  []instrument.Int64ObservableUpDownCounter
+	observableGauges  []instrument.Int64ObservableGauge
+
+	// mu guards the cumulative values of the observable counters, the callback may run for several readers at once.
+	mu             sync.Mutex
+	observedTotals [][]int64
+}
+
+// NewSyntheticMetricCollector creates cfg.Count instruments of each configured kind. rnd draws the metric values and
+// must be safe for concurrent use, nil draws them from a generator seeded with the clock. nameSuffix is appended to
+// every metric name and attrs are added to the common attributes.
+func NewSyntheticMetricCollector(mp metric.MeterProvider, cfg SyntheticMetricsConfig, rnd *rand.Rand, nameSuffix string, attrs ...attribute.KeyValue) (*SyntheticMetricCollector, error) {
+	if rnd == nil {
+		rnd = newRand(0)
+	}
+	smc := &SyntheticMetricCollector{
+		cfg:        cfg,
+		meter:      mp.Meter(instrumentationName),
+		nameSuffix: nameSuffix,
+		attrSets:   syntheticAttributeSets(metricLabels(syntheticMetricCommonLabels, attrs), cfg.AttributeCount, cfg.Cardinality),
+		rand:       rnd,
+	}
+
+	kinds := cfg.Kinds
+	if len(kinds) == 0 {
+		kinds = SyntheticMetricKinds
+	}
+	for _, kind := range kinds {
+		for i := 0; i < cfg.Count; i++ {
+			if err := smc.register(kind, i); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return smc, nil
+}
+
+// syntheticAttributeSets returns cardinality attribute sets, each holding the common labels and attributeCount
+// synthetic attributes. Every set has distinct values so the number of series per instrument equals cardinality.
+func syntheticAttributeSets(common []attribute.KeyValue, attributeCount, cardinality int) [][]attribute.KeyValue {
+	if cardinality < 1 {
+		cardinality = 1
+	}
+	sets := make([][]attribute.KeyValue, cardinality)
+	for i := range sets {
+		attrs := append([]attribute.KeyValue{}, common...)
+		for j := 0; j < attributeCount; j++ {
+			attrs = append(attrs, attribute.String(fmt.Sprintf("synthetic_label_%d", j), fmt.Sprintf("value_%d", i)))
+		}
+		sets[i] = attrs
+	}
+	return sets
+}
+
+// register creates the i-th instrument of the given kind.
+func (smc *SyntheticMetricCollector) register(kind string, i int) error {
+	name := fmt.Sprintf("synthetic_%s_%d%s", kind, i, smc.nameSuffix)
+	desc := instrument.WithDescription(fmt.Sprintf("Synthetic %s number %d used for load testing", kind, i))
+
+	switch kind {
+	case syntheticCounter:
+		c, err := smc.meter.Int64Counter(name, desc, instrument.WithUnit("1"))
+		if err != nil {
+			return err
+		}
+		smc.counters = append(smc.counters, c)
+	case syntheticUpDownCounter:
+		c, err := smc.meter.Int64UpDownCounter(name, desc, instrument.WithUnit("1"))
+		if err != nil {
+			return err
+		}
+		smc.upDownCounters = append(smc.upDownCounters, c)
+	case syntheticHistogram:
+		h, err := smc.meter.Int64Histogram(name, desc, instrument.WithUnit("ms"))
+		if err != nil {
+			return err
+		}
+		smc.histograms = append(smc.histograms, h)
+	case syntheticObservableCounter:
+		c, err := smc.meter.Int64ObservableCounter(name, desc, instrument.WithUnit("1"))
+		if err != nil {
+			return err
+		}
+		smc.observableCounter = append(smc.observableCounter, c)
+		smc.observables = append(smc.observables, c)
+		smc.observedTotals = append(smc.observedTotals, make([]int64, len(smc.attrSets)))
+	case syntheticObservableUpDownCounter:
+		c, err := smc.meter.Int64ObservableUpDownCounter(name, desc, instrument.WithUnit("1"))
+		if err != nil {
+			return err
+		}
+		smc.observableUpDown = append(smc.observableUpDown, c)
+		smc.observables = append(smc.observables, c)
+	case syntheticObservableGauge:
+		g, err := smc.meter.Int64ObservableGauge(name, desc, instrument.WithUnit("1"))
+		if err != nil {
+			return err
+		}
+		smc.observableGauges = append(smc.observableGauges, g)
+		smc.observables = append(smc.observables, g)
+	default:
+		return fmt.Errorf("unknown synthetic metric kind %q", kind)
+	}
+	return nil
+}
+
+// Start registers the callback of the Asynchronous instruments and updates the Synchronous instruments every
+// Interval until ctx is done.
+func (smc *SyntheticMetricCollector) Start(ctx context.Context) error {
+	if len(smc.observables) > 0 {
+		if _, err := smc.meter.RegisterCallback(smc.observe, smc.observables...); err != nil {
+			return err
+		}
+	}
+
+	interval := time.Second * time.Duration(smc.cfg.Interval)
+	if interval <= 0 {
+		interval = time.Second
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			smc.update(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// update records a new random value for every series of the Synchronous instruments.
+func (smc *SyntheticMetricCollector) update(ctx context.Context) {
+	for _, attrs := range smc.attrSets {
+		for _, c := range smc.counters {
+			c.Add(ctx, smc.rand.Int63n(100), attrs...)
+		}
+		for _, c := range smc.upDownCounters {
+			c.Add(ctx, smc.rand.Int63n(21)-10, attrs...)
+		}
+		for _, h := range smc.histograms {
+			h.Record(ctx, smc.rand.Int63n(512), attrs...)
+		}
+	}
+}
+
+// observe reports a value for every series of the Asynchronous instruments. The SDK periodically calls this function
+// to collect data.
+func (smc *SyntheticMetricCollector) observe(ctx context.Context, o metric.Observer) error {
+	smc.mu.Lock()
+	defer smc.mu.Unlock()
+	for i, attrs := range smc.attrSets {
+		for k, c := range smc.observableCounter {
+			smc.observedTotals[k][i] += smc.rand.Int63n(100)
+			o.ObserveInt64(c, smc.observedTotals[k][i], attrs...)
+		}
+		for _, c := range smc.observableUpDown {
+			o.ObserveInt64(c, smc.rand.Int63n(201)-100, attrs...)
+		}
+		for _, g := range smc.observableGauges {
+			o.ObserveInt64(g, smc.rand.Int63n(100), attrs...)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package collection
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Bounds of a single synthetic trace request, which keep its memory and duration reasonable.
+const (
+	maxSyntheticSpans        = 10000
+	maxSyntheticAttrs        = 128
+	maxSyntheticEvents       = 128
+	maxSyntheticSpanLatency  = 5 * time.Second
+	maxSyntheticTotalLatency = time.Minute
+)
+
+// syntheticTraceParams describes the shape of a synthetic trace.
+type syntheticTraceParams struct {
+	depth       int
+	breadth     int
+	spanLatency time.Duration
+	attrs       int
+	events      int
+	errors      map[string]bool
+}
+
+// parseSyntheticTraceParams reads the shape of the synthetic trace from the query string.
+//
+//	depth       levels of the span tree below the endpoint span (default 3)
+//	breadth     children of every span (default 2)
+//	spanLatency simulated work of every span, e.g. 10ms (default 0, at most 5s)
+//	attrs       attributes on every span (default 0, at most 128)
+//	events      events on every span (default 0, at most 128)
+//	errors      comma separated node paths that end in error, e.g. 0,0.1,0.1.2
+//
+// Traces are limited to 10000 spans and to a total simulated work of one minute.
+func parseSyntheticTraceParams(q url.Values) (syntheticTraceParams, error) {
+	p := syntheticTraceParams{depth: 3, breadth: 2, errors: map[string]bool{}}
+
+	ints := []struct {
+		name string
+		dst  *int
+		max  int
+	}{
+		{"depth", &p.depth, maxSyntheticSpans},
+		{"breadth", &p.breadth, maxSyntheticSpans},
+		{"attrs", &p.attrs, maxSyntheticAttrs},
+		{"events", &p.events, maxSyntheticEvents},
+	}
+	for _, param := range ints {
+		if v := q.Get(param.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return p, fmt.Errorf("%s must be a non-negative integer, got %q", param.name, v)
+			}
+			if n > param.max {
+				return p, fmt.Errorf("%s must be at most %d, got %d", param.name, param.max, n)
+			}
+			*param.dst = n
+		}
+	}
+	if v := q.Get("spanLatency"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return p, fmt.Errorf("spanLatency must be a non-negative duration such as 10ms, got %q", v)
+		}
+		if d > maxSyntheticSpanLatency {
+			return p, fmt.Errorf("spanLatency must be at most %s, got %s", maxSyntheticSpanLatency, d)
+		}
+		p.spanLatency = d
+	}
+	if v := q.Get("errors"); v != "" {
+		for _, node := range strings.Split(v, ",") {
+			p.errors[strings.TrimSpace(node)] = true
+		}
+	}
+
+	if p.depth < 1 || p.breadth < 1 {
+		return p, fmt.Errorf("depth and breadth must be at least 1")
+	}
+	spans, level := 0, 1
+	for i := 0; i < p.depth; i++ {
+		spans += level
+		level *= p.breadth
+		if spans > maxSyntheticSpans {
+			return p, fmt.Errorf("a trace of depth %d and breadth %d exceeds %d spans", p.depth, p.breadth, maxSyntheticSpans)
+		}
+	}
+	// The spans run one after the other
+	if total := time.Duration(spans) * p.spanLatency; total > maxSyntheticTotalLatency {
+		return p, fmt.Errorf("%d spans of %s exceed the total latency of %s", spans, p.spanLatency, maxSyntheticTotalLatency)
+	}
+	return p, nil
+}
+
+// SyntheticTrace builds a span tree of the requested depth and breadth in-process and generates an Xray Trace ID.
+// Every span simulates spanLatency of work and carries attrs attributes and events events. The nodes listed in errors,
+// identified by their path from the root such as 0.1.2, record an error.
+func (a *App) SyntheticTrace(w http.ResponseWriter, r *http.Request) {
+	p, err := parseSyntheticTraceParams(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	a.writeResponse(w, a.syntheticTrace(r.Context(), p))
+}
+
+// syntheticTrace is the operation behind SyntheticTrace, shared by the HTTP and gRPC servers.
+func (a *App) syntheticTrace(ctx context.Context, p syntheticTraceParams) response {
+	ctx, span := a.tracer.Start(
+		ctx,
+		"synthetic-trace",
+		trace.WithAttributes(a.traceLabels...),
+		trace.WithAttributes(
+			attribute.Int("synthetic.depth", p.depth),
+			attribute.Int("synthetic.breadth", p.breadth),
+		),
+	)
+	defer span.End()
+
+	a.syntheticSpan(ctx, "0", 1, p)
+	return a.newResponse(span, nil)
+}
+
+// syntheticSpan creates the span of the node at path and, above the last level, its children.
+func (a *App) syntheticSpan(ctx context.Context, path string, level int, p syntheticTraceParams) {
+	attrs := make([]attribute.KeyValue, 0, len(a.traceLabels)+p.attrs+2)
+	attrs = append(attrs, a.traceLabels...)
+	attrs = append(attrs, attribute.String("synthetic.node", path), attribute.Int("synthetic.level", level))
+	for i := 0; i < p.attrs; i++ {
+		attrs = append(attrs, attribute.String(fmt.Sprintf("synthetic.attr.%d", i), fmt.Sprintf("%s-value-%d", path, i)))
+	}
+
+	ctx, span := a.tracer.Start(ctx, "synthetic-span-"+path, trace.WithAttributes(attrs...))
+	defer span.End()
+
+	for i := 0; i < p.events; i++ {
+		span.AddEvent(fmt.Sprintf("synthetic-event-%d", i), trace.WithAttributes(attribute.Int("synthetic.event.index", i)))
+	}
+	if p.spanLatency > 0 {
+		select {
+		case <-time.After(p.spanLatency):
+		case <-ctx.Done():
+		}
+	}
+
+	// A cancelled request stops building the tree
+	if level < p.depth && ctx.Err() == nil {
+		for i := 0; i < p.breadth; i++ {
+			a.syntheticSpan(ctx, fmt.Sprintf("%s.%d", path, i), level+1, p)
+		}
+	}
+
+	if p.errors[path] {
+		err := fmt.Errorf("injected error at node %s", path)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
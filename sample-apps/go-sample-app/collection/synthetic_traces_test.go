@@ -0,0 +1,64 @@
+package collection
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseSyntheticTraceParams(t *testing.T) {
+	tests := []struct {
+		query   string
+		wantErr bool
+	}{
+		{"", false},
+		{"depth=5&breadth=3&spanLatency=10ms&attrs=20&events=5&errors=0.2.1", false},
+		{"attrs=128&events=128&spanLatency=5s&depth=1", false},
+		{"depth=0", true},
+		{"breadth=-1", true},
+		{"depth=20&breadth=2", true},
+		{"attrs=129", true},
+		{"attrs=9223372036854775807", true},
+		{"events=129", true},
+		{"spanLatency=24h", true},
+		{"spanLatency=-1ms", true},
+		{"depth=4&breadth=4&spanLatency=1s", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, _ := url.ParseQuery(tt.query)
+			_, err := parseSyntheticTraceParams(q)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseSyntheticTraceParams(%q) error = %v, want error %v", tt.query, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSyntheticTraceLimits(t *testing.T) {
+	ta := newTestApp(t, testConfig(t))
+	if rec := ta.serve(t, http.MethodGet, "/synthetic-trace?attrs=9223372036854775807"); rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /synthetic-trace with too many attributes = %d, want 400", rec.Code)
+	}
+	if rec := ta.serve(t, http.MethodGet, "/synthetic-trace?depth=2&breadth=2&attrs=2&events=2"); rec.Code != http.StatusOK {
+		t.Errorf("GET /synthetic-trace = %d, want 200", rec.Code)
+	}
+}
+
+func TestSyntheticTraceCancelled(t *testing.T) {
+	ta := newTestApp(t, testConfig(t))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	ta.syntheticTrace(ctx, syntheticTraceParams{depth: 3, breadth: 3, spanLatency: time.Second})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancelled synthetic trace took %s", elapsed)
+	}
+	// The root node and the trace span
+	if got := len(ta.spans.GetSpans()); got != 2 {
+		t.Errorf("recorded %d spans, want the 2 started before the cancellation", got)
+	}
+}
@@ -0,0 +1,315 @@
+package collection
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Contains the validation of the configuration.
+
+// ValidationError lists every problem found in a configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// validator collects the problems found while checking a configuration.
+type validator struct {
+	problems []string
+	// undecoded holds the settings whose value could not be decoded, which are not checked further.
+	undecoded map[string]bool
+}
+
+func (v *validator) addf(format string, args ...interface{}) {
+	problem := fmt.Sprintf(format, args...)
+	if key, _, ok := strings.Cut(problem, ":"); ok && v.undecoded[key] {
+		return
+	}
+	v.problems = append(v.problems, problem)
+}
+
+// decodeError reports a value that could not be decoded into its setting.
+func (v *validator) decodeError(err string) {
+	key := decodeErrorKey(err)
+	if key == "" {
+		v.addf("%s", err)
+		return
+	}
+	v.addf("%s: %s", key, strings.Replace(err, "'"+key+"' ", "", 1))
+	if v.undecoded == nil {
+		v.undecoded = map[string]bool{}
+	}
+	v.undecoded[key] = true
+}
+
+// port checks that value is a TCP port number.
+func (v *validator) port(key, value string) {
+	if n, err := strconv.Atoi(value); err != nil || n < 1 || n > 65535 {
+		v.addf("%s: %q is not a port number between 1 and 65535", key, value)
+	}
+}
+
+// atLeast checks that value is not lower than min.
+func (v *validator) atLeast(key string, value, min int64) {
+	if value < min {
+		v.addf("%s: must be at least %d, got %d", key, min, value)
+	}
+}
+
+// oneOf checks that value is one of allowed.
+func (v *validator) oneOf(key, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.addf("%s: %q is not one of %s", key, value, strings.Join(allowed, ", "))
+}
+
+// hostnameLabel matches a label of a host name.
+var hostnameLabel = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?$`)
+
+// host checks that value is an IP address or a host name, without a port. Empty stands for every interface.
+func (v *validator) host(key, value string) {
+	if value == "" || net.ParseIP(value) != nil {
+		return
+	}
+	labels := strings.Split(value, ".")
+	for _, label := range labels {
+		if len(label) > 63 || !hostnameLabel.MatchString(label) {
+			v.addf("%s: %q is not an IP address or a host name", key, value)
+			return
+		}
+	}
+}
+
+// url checks that value is an absolute http or https URL.
+func (v *validator) url(key, value string) {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.addf("%s: %q is not an http or https URL", key, value)
+	}
+}
+
+// keyValues checks that every pair is of the form key=value.
+func (v *validator) keyValues(key string, pairs []string) {
+	for i, pair := range pairs {
+		if k, _, ok := strings.Cut(pair, "="); !ok || strings.TrimSpace(k) == "" {
+			v.addf("%s[%d]: %q is not a key=value pair", key, i, pair)
+		}
+	}
+}
+
+// attributes checks the static and environment attributes of a signal, whose keys cannot be protected keys.
+func (v *validator) attributes(key string, cfg AttributesConfig) {
+	v.keyValues(key+".Static", cfg.Static)
+	v.keyValues(key+".FromEnv", cfg.FromEnv)
+	for _, kv := range keyValueAttributes(append(cfg.Static, cfg.FromEnv...)) {
+		if isProtectedAttribute(string(kv.Key)) {
+			v.addf("%s: %s is set by the sample app and cannot be overridden", key, kv.Key)
+		}
+	}
+}
+
+// Validate checks the ranges, ports, URLs and enumerations of the configuration, and reports the keys of the
+// configuration file that do not match any setting and the values of the wrong type. All problems are reported at
+// once in a *ValidationError.
+func (c *Config) Validate() error {
+	v := &validator{}
+	for _, key := range c.unknownKeys {
+		v.addf("%s: unknown configuration key", key)
+	}
+	for _, err := range c.decodeErrors {
+		v.decodeError(err)
+	}
+
+	v.host("Host", c.Host)
+	v.port("Port", c.Port)
+	if c.GrpcPort != "" {
+		v.port("GrpcPort", c.GrpcPort)
+		if c.GrpcPort == c.Port {
+			v.addf("GrpcPort: must differ from Port %s", c.Port)
+		}
+	}
+	for i, port := range c.SampleAppPorts {
+		v.port(fmt.Sprintf("SampleAppPorts[%d]", i), port)
+	}
+	v.oneOf("SampleAppProtocol", c.SampleAppProtocol, protocolHttp, protocolGrpc)
+	v.oneOf("ResponseTraceIdFormat", c.ResponseTraceIdFormat, traceIdFormatXray, traceIdFormatW3C)
+
+	v.attributes("CommonAttributes.All", c.CommonAttributes.All)
+	v.attributes("CommonAttributes.Traces", c.CommonAttributes.Traces)
+	v.attributes("CommonAttributes.Metrics", c.CommonAttributes.Metrics)
+
+	v.oneOf("TraceExporter", c.TraceExporter, traceExporterOtlp, traceExporterXray, traceExporterSigV4)
+	v.oneOf("LogExporter", c.LogExporter, logExporterNone, logExporterSigV4)
+	if c.SemconvStability != "" {
+		v.oneOf("SemconvStability", c.SemconvStability, semconvOld, semconvStable, semconvDup)
+	}
+	if c.SigV4.TracesEndpoint != "" {
+		v.url("SigV4.TracesEndpoint", c.SigV4.TracesEndpoint)
+	}
+	if c.SigV4.LogsEndpoint != "" {
+		v.url("SigV4.LogsEndpoint", c.SigV4.LogsEndpoint)
+	}
+	if c.LogExporter == logExporterSigV4 && (c.SigV4.LogGroup == "" || c.SigV4.LogStream == "") {
+		v.addf("SigV4: LogGroup and LogStream are required when LogExporter is %s", logExporterSigV4)
+	}
+	if c.XrayDaemon.Address != "" {
+		if _, port, err := net.SplitHostPort(c.XrayDaemon.Address); err != nil {
+			v.addf("XrayDaemon.Address: %q is not a host:port address", c.XrayDaemon.Address)
+		} else {
+			v.port("XrayDaemon.Address", port)
+		}
+	}
+	v.atLeast("XrayDaemon.BatchSize", int64(c.XrayDaemon.BatchSize), 1)
+	v.atLeast("XrayDaemon.BatchTimeout", c.XrayDaemon.BatchTimeout, 1)
+
+	xa := c.XrayAnnotations
+	v.keyValues("XrayAnnotations.Static", xa.Static)
+	v.keyValues("XrayAnnotations.FromHeaders", xa.FromHeaders)
+	for _, kv := range keyValueAttributes(append(xa.Static, xa.FromHeaders...)) {
+		if isProtectedAttribute(string(kv.Key)) {
+			v.addf("XrayAnnotations: %s is set by the sample app and cannot be overridden, list it in Keys instead", kv.Key)
+		}
+	}
+	for i, key := range xa.Keys {
+		if strings.TrimSpace(key) == "" {
+			v.addf("XrayAnnotations.Keys[%d]: empty key", i)
+		}
+	}
+	for i, key := range xa.FromBaggage {
+		if strings.TrimSpace(key) == "" {
+			v.addf("XrayAnnotations.FromBaggage[%d]: empty key", i)
+		}
+	}
+
+	v.atLeast("TimeInterval", c.TimeInterval, 1)
+	v.atLeast("RandomTimeAliveIncrementer", c.TimeAliveIncrementer, 0)
+	v.atLeast("RandomTotalHeapSizeUpperBound", c.TotalHeapSizeUpperBound, 1)
+	v.atLeast("RandomThreadsActiveUpperBound", c.ThreadsActiveUpperBound, 0)
+	v.atLeast("RandomCpuUsageUpperBound", c.CpuUsageUpperBound, 1)
+
+	sm := c.SyntheticMetrics
+	v.atLeast("SyntheticMetrics.Count", int64(sm.Count), 0)
+	for i, kind := range sm.Kinds {
+		v.oneOf(fmt.Sprintf("SyntheticMetrics.Kinds[%d]", i), kind, SyntheticMetricKinds...)
+	}
+	v.atLeast("SyntheticMetrics.AttributeCount", int64(sm.AttributeCount), 0)
+	v.atLeast("SyntheticMetrics.Cardinality", int64(sm.Cardinality), 1)
+	if sm.Cardinality > 1 && sm.AttributeCount == 0 {
+		v.addf("SyntheticMetrics.Cardinality: requires AttributeCount >= 1")
+	}
+	v.atLeast("SyntheticMetrics.Interval", sm.Interval, 1)
+
+	v.atLeast("Database.Rows", int64(c.Database.Rows), 0)
+	v.atLeast("Database.SelectRows", int64(c.Database.SelectRows), 0)
+	for i, kind := range c.Database.QueryMix {
+		v.oneOf(fmt.Sprintf("Database.QueryMix[%d]", i), kind, DbQueryKinds...)
+	}
+
+	m := c.Messaging
+	v.oneOf("Messaging.Queue", m.Queue, queueMemory, queueSqs)
+	if m.Queue == queueSqs {
+		if m.QueueUrl == "" {
+			v.addf("Messaging.QueueUrl: required when Messaging.Queue is %s", queueSqs)
+		} else {
+			v.url("Messaging.QueueUrl", m.QueueUrl)
+		}
+	}
+	if m.SqsEndpoint != "" {
+		v.url("Messaging.SqsEndpoint", m.SqsEndpoint)
+	}
+	v.atLeast("Messaging.Capacity", int64(m.Capacity), 1)
+	v.atLeast("Messaging.Consumers", int64(m.Consumers), 0)
+	v.atLeast("Messaging.ProcessingTime", m.ProcessingTime, 0)
+
+	names := map[string]bool{}
+	for i, job := range c.Jobs {
+		key := fmt.Sprintf("Jobs[%d]", i)
+		if job.Name == "" {
+			v.addf("%s.Name: required", key)
+		} else if names[job.Name] {
+			v.addf("%s.Name: duplicate job name %q", key, job.Name)
+		}
+		names[job.Name] = true
+		if _, err := jobScheduleParser.Parse(job.Schedule); err != nil {
+			v.addf("%s.Schedule: %q is not a cron spec or descriptor: %v", key, job.Schedule, err)
+		}
+		v.atLeast(key+".Duration", job.Duration, 0)
+		for j, call := range job.Calls {
+			v.url(fmt.Sprintf("%s.Calls[%d]", key, j), call)
+		}
+		if job.OverlapPolicy != "" {
+			v.oneOf(key+".OverlapPolicy", job.OverlapPolicy, overlapAllow, overlapSkip, overlapDelay)
+		}
+	}
+
+	routes := map[string]bool{}
+	for i, e := range c.Endpoints {
+		key := fmt.Sprintf("Endpoints[%d]", i)
+		method := strings.ToUpper(e.Method)
+		if method == "" {
+			method = "GET"
+		}
+		switch {
+		case !strings.HasPrefix(e.Path, "/"):
+			v.addf("%s.Path: %q must start with /", key, e.Path)
+		case routes[method+" "+e.Path]:
+			v.addf("%s.Path: duplicate endpoint %s %s", key, method, e.Path)
+		default:
+			for _, p := range builtinPaths {
+				if e.Path == p {
+					v.addf("%s.Path: %s is a built-in endpoint", key, e.Path)
+				}
+			}
+		}
+		routes[method+" "+e.Path] = true
+		v.oneOf(key+".Method", method, "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS")
+
+		l := e.Latency
+		if l.Distribution != "" {
+			v.oneOf(key+".Latency.Distribution", l.Distribution, latencyFixed, latencyUniform, latencyNormal, latencyExponential)
+		}
+		v.atLeast(key+".Latency.Mean", l.Mean, 0)
+		v.atLeast(key+".Latency.StdDev", l.StdDev, 0)
+		v.atLeast(key+".Latency.Min", l.Min, 0)
+		if l.Max != 0 {
+			v.atLeast(key+".Latency.Max", l.Max, l.Min)
+		} else if l.Distribution == latencyUniform {
+			v.addf("%s.Latency.Max: required by the uniform distribution", key)
+		}
+
+		for j, call := range e.Calls {
+			callKey := fmt.Sprintf("%s.Calls[%d]", key, j)
+			switch {
+			case (call.Url == "") == (call.Peer == ""):
+				v.addf("%s: exactly one of Url and Peer is required", callKey)
+			case call.Url != "":
+				v.url(callKey+".Url", call.Url)
+			default:
+				v.port(callKey+".Peer", call.Peer)
+			}
+		}
+		if e.ErrorRate < 0 || e.ErrorRate > 1 {
+			v.addf("%s.ErrorRate: must be between 0 and 1, got %g", key, e.ErrorRate)
+		}
+		if e.ErrorStatus != 0 && (e.ErrorStatus < 400 || e.ErrorStatus > 599) {
+			v.addf("%s.ErrorStatus: must be an HTTP error status between 400 and 599, got %d", key, e.ErrorStatus)
+		}
+		v.atLeast(key+".ResponseSize", int64(e.ResponseSize), 0)
+		v.keyValues(key+".Attributes", e.Attributes)
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package collection
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// validationProblems returns the problems reported by Validate, or nil when cfg is valid.
+func validationProblems(t *testing.T, cfg *Config) []string {
+	t.Helper()
+	err := cfg.Validate()
+	if err == nil {
+		return nil
+	}
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Validate() returned %T, want a *ValidationError", err)
+	}
+	return verr.Problems
+}
+
+func TestValidateDefaults(t *testing.T) {
+	if problems := validationProblems(t, testConfig(t)); problems != nil {
+		t.Errorf("the default configuration is invalid: %q", problems)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(c *Config)
+		want   string
+	}{
+		{"port", func(c *Config) { c.Port = "70000" }, "Port:"},
+		{"gRPC port", func(c *Config) { c.GrpcPort = "x" }, "GrpcPort:"},
+		{"gRPC port equal to port", func(c *Config) { c.GrpcPort = c.Port }, "GrpcPort: must differ"},
+		{"sample app ports", func(c *Config) { c.SampleAppPorts = []string{"8081", "0"} }, "SampleAppPorts[1]:"},
+		{"protocol", func(c *Config) { c.SampleAppProtocol = "udp" }, "SampleAppProtocol:"},
+		{"trace ID format", func(c *Config) { c.ResponseTraceIdFormat = "b3" }, "ResponseTraceIdFormat:"},
+		{"common attribute pair", func(c *Config) { c.CommonAttributes.All.Static = []string{"team"} }, "CommonAttributes.All.Static[0]:"},
+		{"protected common attribute", func(c *Config) { c.CommonAttributes.Metrics.Static = []string{"signal=x"} }, "CommonAttributes.Metrics: signal"},
+		{"trace exporter", func(c *Config) { c.TraceExporter = "zipkin" }, "TraceExporter:"},
+		{"log exporter", func(c *Config) { c.LogExporter = "stdout" }, "LogExporter:"},
+		{"semconv stability", func(c *Config) { c.SemconvStability = "new" }, "SemconvStability:"},
+		{"traces endpoint", func(c *Config) { c.SigV4.TracesEndpoint = "xray.local" }, "SigV4.TracesEndpoint:"},
+		{"logs endpoint", func(c *Config) { c.SigV4.LogsEndpoint = "ftp://logs" }, "SigV4.LogsEndpoint:"},
+		{"log group", func(c *Config) { c.LogExporter = logExporterSigV4 }, "SigV4: LogGroup and LogStream are required"},
+		{"daemon address", func(c *Config) { c.XrayDaemon.Address = "localhost" }, "XrayDaemon.Address:"},
+		{"daemon port", func(c *Config) { c.XrayDaemon.Address = "localhost:0" }, "XrayDaemon.Address:"},
+		{"batch size", func(c *Config) { c.XrayDaemon.BatchSize = 0 }, "XrayDaemon.BatchSize:"},
+		{"batch timeout", func(c *Config) { c.XrayDaemon.BatchTimeout = 0 }, "XrayDaemon.BatchTimeout:"},
+		{"static annotation", func(c *Config) { c.XrayAnnotations.Static = []string{"x"} }, "XrayAnnotations.Static[0]:"},
+		{"header annotation", func(c *Config) { c.XrayAnnotations.FromHeaders = []string{"=X-Tenant"} }, "XrayAnnotations.FromHeaders[0]:"},
+		{"protected annotation", func(c *Config) { c.XrayAnnotations.Static = []string{"host=x"} }, "XrayAnnotations: host"},
+		{"annotation key", func(c *Config) { c.XrayAnnotations.Keys = []string{" "} }, "XrayAnnotations.Keys[0]:"},
+		{"baggage annotation", func(c *Config) { c.XrayAnnotations.FromBaggage = []string{""} }, "XrayAnnotations.FromBaggage[0]:"},
+		{"time interval", func(c *Config) { c.TimeInterval = 0 }, "TimeInterval:"},
+		{"time alive incrementer", func(c *Config) { c.TimeAliveIncrementer = -1 }, "RandomTimeAliveIncrementer:"},
+		{"heap size", func(c *Config) { c.TotalHeapSizeUpperBound = 0 }, "RandomTotalHeapSizeUpperBound:"},
+		{"threads active", func(c *Config) { c.ThreadsActiveUpperBound = -1 }, "RandomThreadsActiveUpperBound:"},
+		{"cpu usage", func(c *Config) { c.CpuUsageUpperBound = 0 }, "RandomCpuUsageUpperBound:"},
+		{"synthetic count", func(c *Config) { c.SyntheticMetrics.Count = -1 }, "SyntheticMetrics.Count:"},
+		{"synthetic kind", func(c *Config) { c.SyntheticMetrics.Kinds = []string{"summary"} }, "SyntheticMetrics.Kinds[0]:"},
+		{"synthetic attribute count", func(c *Config) { c.SyntheticMetrics.AttributeCount = -1 }, "SyntheticMetrics.AttributeCount:"},
+		{"synthetic cardinality", func(c *Config) { c.SyntheticMetrics.Cardinality = 0 }, "SyntheticMetrics.Cardinality:"},
+		{"synthetic cardinality without attributes", func(c *Config) {
+			c.SyntheticMetrics.Cardinality, c.SyntheticMetrics.AttributeCount = 10, 0
+		}, "SyntheticMetrics.Cardinality: requires AttributeCount"},
+		{"synthetic interval", func(c *Config) { c.SyntheticMetrics.Interval = 0 }, "SyntheticMetrics.Interval:"},
+		{"database rows", func(c *Config) { c.Database.Rows = -1 }, "Database.Rows:"},
+		{"database select rows", func(c *Config) { c.Database.SelectRows = -1 }, "Database.SelectRows:"},
+		{"query mix", func(c *Config) { c.Database.QueryMix = []string{"select", "drop"} }, "Database.QueryMix[1]:"},
+		{"queue", func(c *Config) { c.Messaging.Queue = "kafka" }, "Messaging.Queue:"},
+		{"queue URL required", func(c *Config) { c.Messaging.Queue = queueSqs }, "Messaging.QueueUrl: required"},
+		{"queue URL", func(c *Config) { c.Messaging.Queue, c.Messaging.QueueUrl = queueSqs, "queue" }, "Messaging.QueueUrl:"},
+		{"SQS endpoint", func(c *Config) { c.Messaging.SqsEndpoint = "localhost:9324" }, "Messaging.SqsEndpoint:"},
+		{"queue capacity", func(c *Config) { c.Messaging.Capacity = 0 }, "Messaging.Capacity:"},
+		{"consumers", func(c *Config) { c.Messaging.Consumers = -1 }, "Messaging.Consumers:"},
+		{"processing time", func(c *Config) { c.Messaging.ProcessingTime = -1 }, "Messaging.ProcessingTime:"},
+		{"host with port", func(c *Config) { c.Host = "localhost:8080" }, "Host:"},
+		{"host with scheme", func(c *Config) { c.Host = "http://localhost" }, "Host:"},
+		{"job name", func(c *Config) { c.Jobs = []JobConfig{{Schedule: "@every 1m"}} }, "Jobs[0].Name: required"},
+		{"duplicate job", func(c *Config) {
+			c.Jobs = []JobConfig{{Name: "a", Schedule: "@every 1m"}, {Name: "a", Schedule: "@every 1m"}}
+		}, "Jobs[1].Name: duplicate"},
+		{"job schedule", func(c *Config) { c.Jobs = []JobConfig{{Name: "a", Schedule: "often"}} }, "Jobs[0].Schedule:"},
+		{"job duration", func(c *Config) { c.Jobs = []JobConfig{{Name: "a", Schedule: "@hourly", Duration: -1}} }, "Jobs[0].Duration:"},
+		{"job call", func(c *Config) { c.Jobs = []JobConfig{{Name: "a", Schedule: "@hourly", Calls: []string{"x"}}} }, "Jobs[0].Calls[0]:"},
+		{"job overlap policy", func(c *Config) {
+			c.Jobs = []JobConfig{{Name: "a", Schedule: "@hourly", OverlapPolicy: "queue"}}
+		}, "Jobs[0].OverlapPolicy:"},
+		{"endpoint path", func(c *Config) { c.Endpoints = []EndpointConfig{{Path: "checkout"}} }, "Endpoints[0].Path: \"checkout\" must start with /"},
+		{"duplicate endpoint", func(c *Config) { c.Endpoints = []EndpointConfig{{Path: "/a"}, {Path: "/a", Method: "get"}} }, "Endpoints[1].Path: duplicate"},
+		{"built-in endpoint", func(c *Config) { c.Endpoints = []EndpointConfig{{Path: "/db-call"}} }, "Endpoints[0].Path: /db-call is a built-in"},
+		{"endpoint method", func(c *Config) { c.Endpoints = []EndpointConfig{{Path: "/a", Method: "FETCH"}} }, "Endpoints[0].Method:"},
+		{"latency distribution", func(c *Config) {
+			c.Endpoints = []EndpointConfig{{Path: "/a", Latency: LatencyConfig{Distribution: "poisson"}}}
+		}, "Endpoints[0].Latency.Distribution:"},
+		{"latency mean", func(c *Config) { c.Endpoints = []EndpointConfig{{Path: "/a", Latency: LatencyConfig{Mean: -1}}} }, "Endpoints[0].Latency.Mean:"},
+		{"latency standard deviation", func(c *Config) {
+			c.Endpoints = []EndpointConfig{{Path: "/a", Latency: LatencyConfig{StdDev: -1}}}
+		}, "Endpoints[0].Latency.StdDev:"},
+		{"latency min", func(c *Config) { c.Endpoints = []EndpointConfig{{Path: "/a", Latency: LatencyConfig{Min: -1}}} }, "Endpoints[0].Latency.Min:"},
+		{"latency max", func(c *Config) {
+			c.Endpoints = []EndpointConfig{{Path: "/a", Latency: LatencyConfig{Min: 10, Max: 5}}}
+		}, "Endpoints[0].Latency.Max:"},
+		{"uniform latency max", func(c *Config) {
+			c.Endpoints = []EndpointConfig{{Path: "/a", Latency: LatencyConfig{Distribution: latencyUniform}}}
+		}, "Endpoints[0].Latency.Max: required"},
+		{"endpoint call target", func(c *Config) {
+			c.Endpoints = []EndpointConfig{{Path: "/a", Calls: []EndpointCallConfig{{Url: "http://a", Peer: "8081"}}}}
+		}, "Endpoints[0].Calls[0]: exactly one"},
+		{"endpoint call URL", func(c *Config) {
+			c.Endpoints = []EndpointConfig{{Path: "/a", Calls: []EndpointCallConfig{{Url: "a"}}}}
+		}, "Endpoints[0].Calls[0].Url:"},
+		{"endpoint call peer", func(c *Config) {
+			c.Endpoints = []EndpointConfig{{Path: "/a", Calls: []EndpointCallConfig{{Peer: "a"}}}}
+		}, "Endpoints[0].Calls[0].Peer:"},
+		{"error rate", func(c *Config) { c.Endpoints = []EndpointConfig{{Path: "/a", ErrorRate: 2}} }, "Endpoints[0].ErrorRate:"},
+		{"error status", func(c *Config) { c.Endpoints = []EndpointConfig{{Path: "/a", ErrorStatus: 302}} }, "Endpoints[0].ErrorStatus:"},
+		{"response size", func(c *Config) { c.Endpoints = []EndpointConfig{{Path: "/a", ResponseSize: -1}} }, "Endpoints[0].ResponseSize:"},
+		{"endpoint attributes", func(c *Config) { c.Endpoints = []EndpointConfig{{Path: "/a", Attributes: []string{"x"}}} }, "Endpoints[0].Attributes[0]:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			tt.mutate(cfg)
+			problems := validationProblems(t, cfg)
+			if len(problems) != 1 || !strings.HasPrefix(problems[0], tt.want) {
+				t.Errorf("Validate() problems = %q, want one starting with %q", problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Port = "0"
+	cfg.TraceExporter = "zipkin"
+	cfg.Messaging.Capacity = 0
+	cfg.Endpoints = []EndpointConfig{{Path: "/a", ErrorRate: -1}}
+	problems := validationProblems(t, cfg)
+	if len(problems) != 4 {
+		t.Errorf("Validate() problems = %q, want 4", problems)
+	}
+}
+
+func TestLoadConfigurationReportsEveryProblem(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `
+Port: "99999"
+Bogus: true
+XrayDaemon:
+  BatchSize: lots
+Jobs:
+  - Name: nightly
+    Schedule: "@daily"
+    Duration: soon
+  - Name: nightly
+    Schedule: "@daily"
+`
+	if err := os.WriteFile(file, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("INSTANCE_ID", "")
+	cfg, err := LoadConfiguration(file, nil)
+	if err != nil {
+		t.Fatalf("LoadConfiguration() = %v, want the problems reported by Validate", err)
+	}
+
+	problems := validationProblems(t, cfg)
+	want := []string{
+		"bogus: unknown configuration key",
+		"XrayDaemon.BatchSize: cannot parse",
+		"Jobs[0].Duration: cannot parse",
+		"Port:",
+		"Jobs[1].Name: duplicate",
+	}
+	for _, prefix := range want {
+		found := false
+		for _, problem := range problems {
+			found = found || strings.HasPrefix(problem, prefix)
+		}
+		if !found {
+			t.Errorf("no problem starting with %q in %q", prefix, problems)
+		}
+	}
+	// The settings that could not be decoded are not reported twice
+	if len(problems) != len(want) {
+		t.Errorf("Validate() problems = %q, want %d", problems, len(want))
+	}
+}
+
+func TestValidateHost(t *testing.T) {
+	for _, host := range []string{"", "0.0.0.0", "::1", "localhost", "sample-app.internal"} {
+		cfg := testConfig(t)
+		cfg.Host = host
+		if problems := validationProblems(t, cfg); len(problems) != 0 {
+			t.Errorf("Host %q: Validate() problems = %q, want none", host, problems)
+		}
+	}
+}
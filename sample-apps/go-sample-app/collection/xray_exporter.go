@@ -0,0 +1,389 @@
+package collection
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Contains the exporter sending spans to the X-Ray daemon as segment documents over UDP.
+
+// Trace exporters.
+const (
+	traceExporterOtlp  = "otlp"
+	traceExporterXray  = "xray"
+	traceExporterSigV4 = "sigv4"
+)
+
+const defaultXrayDaemonAddress = "127.0.0.1:2000"
+
+// xrayDaemonHeader precedes every segment document sent to the daemon.
+const xrayDaemonHeader = `{"format": "json", "version": 1}` + "\n"
+
+// maxXrayDatagram is the largest document the daemon accepts in a single UDP datagram.
+const maxXrayDatagram = 64 * 1024
+
+// netPeerIPKey is the peer address attribute of semconv v1.12.0, set by otelmux on server spans.
+const netPeerIPKey = attribute.Key("net.peer.ip")
+
+// xrayAnnotationsKey is the span attribute listing the keys of the attributes recorded as X-Ray annotations, which
+// are indexed for filter expressions. The other attributes are recorded as metadata.
+const xrayAnnotationsKey = attribute.Key("aws.xray.annotations")
+
+// maxXrayNameLength is the maximum number of characters of a segment name.
+const maxXrayNameLength = 200
+
+// xrayNameChars matches the characters X-Ray does not accept in segment names.
+var xrayNameChars = regexp.MustCompile(`[^\p{L}\p{N}\s_.:/%&#=+\\\-@]`)
+
+// xrayAnnotationKeyChars matches the characters X-Ray does not accept in annotation keys.
+var xrayAnnotationKeyChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// xraySegment is a segment or independent subsegment document of the X-Ray daemon protocol.
+type xraySegment struct {
+	Name        string                            `json:"name"`
+	ID          string                            `json:"id"`
+	TraceID     string                            `json:"trace_id"`
+	ParentID    string                            `json:"parent_id,omitempty"`
+	Type        string                            `json:"type,omitempty"`
+	StartTime   float64                           `json:"start_time"`
+	EndTime     float64                           `json:"end_time"`
+	Namespace   string                            `json:"namespace,omitempty"`
+	Fault       bool                              `json:"fault,omitempty"`
+	Error       bool                              `json:"error,omitempty"`
+	Throttle    bool                              `json:"throttle,omitempty"`
+	Cause       *xrayCause                        `json:"cause,omitempty"`
+	HTTP        *xrayHTTP                         `json:"http,omitempty"`
+	AWS         map[string]interface{}            `json:"aws,omitempty"`
+	Annotations map[string]interface{}            `json:"annotations,omitempty"`
+	Metadata    map[string]map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// xrayHTTP is the http block of a segment.
+type xrayHTTP struct {
+	Request  *xrayHTTPRequest  `json:"request,omitempty"`
+	Response *xrayHTTPResponse `json:"response,omitempty"`
+}
+
+type xrayHTTPRequest struct {
+	Method    string `json:"method,omitempty"`
+	URL       string `json:"url,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	ClientIP  string `json:"client_ip,omitempty"`
+}
+
+type xrayHTTPResponse struct {
+	Status        int64 `json:"status,omitempty"`
+	ContentLength int64 `json:"content_length,omitempty"`
+}
+
+// xrayCause describes the exceptions recorded on a span.
+type xrayCause struct {
+	Exceptions []xrayException `json:"exceptions"`
+}
+
+type xrayException struct {
+	ID      string `json:"id"`
+	Type    string `json:"type,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// XrayDaemonExporter is a span exporter sending every span to the X-Ray daemon as a segment document over UDP.
+// Server spans and spans without a local parent become segments, the other spans become independent subsegments of
+// their parent. Spans must have X-Ray compatible trace IDs.
+type XrayDaemonExporter struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewXrayDaemonExporter returns an exporter sending segments to the daemon listening on address. The address
+// defaults to AWS_XRAY_DAEMON_ADDRESS, then to 127.0.0.1:2000.
+func NewXrayDaemonExporter(address string) (*XrayDaemonExporter, error) {
+	conn, err := net.Dial("udp", xrayDaemonAddress(address))
+	if err != nil {
+		return nil, err
+	}
+	return &XrayDaemonExporter{conn: conn}, nil
+}
+
+// xrayDaemonAddress returns address, or the default address of the daemon when it is empty.
+func xrayDaemonAddress(address string) string {
+	if address == "" {
+		address = os.Getenv("AWS_XRAY_DAEMON_ADDRESS")
+	}
+	if address == "" {
+		address = defaultXrayDaemonAddress
+	}
+	return address
+}
+
+// ExportSpans sends one segment document per span. Spans that cannot be sent are reported in the returned error.
+func (e *XrayDaemonExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn == nil {
+		return nil
+	}
+
+	var (
+		failed   int
+		firstErr error
+	)
+	for _, span := range spans {
+		payload, err := json.Marshal(spanToSegment(span))
+		if err == nil && len(xrayDaemonHeader)+len(payload) > maxXrayDatagram {
+			err = fmt.Errorf("segment of span %s exceeds %d bytes", span.Name(), maxXrayDatagram)
+		}
+		if err == nil {
+			_, err = e.conn.Write(append([]byte(xrayDaemonHeader), payload...))
+		}
+		if err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if firstErr != nil {
+		return fmt.Errorf("%d of %d segments not sent: %w", failed, len(spans), firstErr)
+	}
+	return nil
+}
+
+// Shutdown closes the connection to the daemon.
+func (e *XrayDaemonExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn == nil {
+		return nil
+	}
+	err := e.conn.Close()
+	e.conn = nil
+	return err
+}
+
+// spanToSegment converts span to a segment document.
+func spanToSegment(span sdktrace.ReadOnlySpan) xraySegment {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	withOldHTTPAttributes(span.SpanKind(), attrs)
+	sc := span.SpanContext()
+	seg := xraySegment{
+		ID:        sc.SpanID().String(),
+		TraceID:   xrayTraceID(sc.TraceID()),
+		StartTime: xrayTime(span.StartTime()),
+		EndTime:   xrayTime(span.EndTime()),
+	}
+	parent := span.Parent()
+	if parent.IsValid() {
+		seg.ParentID = parent.SpanID().String()
+	}
+
+	isSegment := span.SpanKind() == trace.SpanKindServer || !parent.IsValid() || parent.IsRemote()
+	if isSegment {
+		seg.Name = serviceNameOf(span)
+		seg.AWS = map[string]interface{}{
+			"xray": map[string]interface{}{"sdk": "opentelemetry for go", "sdk_version": otel.Version()},
+		}
+	} else {
+		seg.Type = "subsegment"
+		seg.Name = subsegmentName(span, attrs)
+		if span.SpanKind() == trace.SpanKindClient || span.SpanKind() == trace.SpanKindProducer {
+			seg.Namespace = "remote"
+		}
+	}
+	seg.Name = xrayName(seg.Name)
+
+	if attrs[semconv.RPCSystemKey].AsString() == "aws-api" {
+		if !isSegment {
+			seg.Namespace = "aws"
+		}
+		if seg.AWS == nil {
+			seg.AWS = map[string]interface{}{}
+		}
+		seg.AWS["operation"] = attrs[semconv.RPCMethodKey].AsString()
+		for _, key := range []string{"aws.region", "aws.request_id", "aws.queue_url"} {
+			if v, ok := attrs[attribute.Key(key)]; ok {
+				seg.AWS[strings.TrimPrefix(key, "aws.")] = v.AsString()
+			}
+		}
+	}
+
+	seg.HTTP = httpBlock(span, attrs)
+	setXrayStatus(&seg, span, attrs)
+	setAnnotationsAndMetadata(&seg, span.Attributes(), attrs[xrayAnnotationsKey].AsStringSlice())
+	return seg
+}
+
+// xrayTime converts t to seconds since the epoch.
+func xrayTime(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+// xrayName truncates name to 200 characters and removes the characters X-Ray does not accept.
+func xrayName(name string) string {
+	name = xrayNameChars.ReplaceAllString(name, "")
+	if utf8.RuneCountInString(name) > maxXrayNameLength {
+		name = string([]rune(name)[:maxXrayNameLength])
+	}
+	return name
+}
+
+// serviceNameOf returns the service name of the resource of span.
+func serviceNameOf(span sdktrace.ReadOnlySpan) string {
+	if res := span.Resource(); res != nil {
+		if v, ok := res.Set().Value(semconv.ServiceNameKey); ok {
+			return v.AsString()
+		}
+	}
+	return serviceName
+}
+
+// subsegmentName names the subsegment of span after the called service when it is a client span, so that the
+// service map shows the downstream node.
+func subsegmentName(span sdktrace.ReadOnlySpan, attrs map[attribute.Key]attribute.Value) string {
+	if span.SpanKind() == trace.SpanKindClient {
+		if v, ok := attrs[semconv.RPCServiceKey]; ok && attrs[semconv.RPCSystemKey].AsString() == "aws-api" {
+			return v.AsString()
+		}
+		if v, ok := attrs[semconv.DBNameKey]; ok {
+			return v.AsString()
+		}
+		if v, ok := attrs[semconv.NetPeerNameKey]; ok {
+			return v.AsString()
+		}
+		if v, ok := attrs[semconv.HTTPURLKey]; ok {
+			if u, err := url.Parse(v.AsString()); err == nil && u.Hostname() != "" {
+				return u.Hostname()
+			}
+		}
+	}
+	return span.Name()
+}
+
+// httpBlock returns the http block of spans with the http.* semantic attributes.
+func httpBlock(span sdktrace.ReadOnlySpan, attrs map[attribute.Key]attribute.Value) *xrayHTTP {
+	method, ok := attrs[semconv.HTTPMethodKey]
+	if !ok {
+		return nil
+	}
+	req := &xrayHTTPRequest{
+		Method:    method.AsString(),
+		URL:       attrs[semconv.HTTPURLKey].AsString(),
+		UserAgent: attrs[semconv.HTTPUserAgentKey].AsString(),
+		ClientIP:  attrs[semconv.HTTPClientIPKey].AsString(),
+	}
+	if req.URL == "" {
+		// Server spans describe the URL by its parts
+		host := attrs[semconv.NetHostNameKey].AsString()
+		if port := attrs[semconv.NetHostPortKey].AsInt64(); port != 0 {
+			host = net.JoinHostPort(host, fmt.Sprint(port))
+		}
+		scheme := attrs[semconv.HTTPSchemeKey].AsString()
+		if scheme == "" {
+			scheme = "http"
+		}
+		req.URL = scheme + "://" + host + attrs[semconv.HTTPTargetKey].AsString()
+	}
+	if req.ClientIP == "" && span.SpanKind() == trace.SpanKindServer {
+		// otelhttp sets the peer address in net.sock.peer.addr and otelmux in net.peer.ip
+		req.ClientIP = attrs[semconv.NetSockPeerAddrKey].AsString()
+		if req.ClientIP == "" {
+			req.ClientIP = attrs[netPeerIPKey].AsString()
+		}
+	}
+	block := &xrayHTTP{Request: req}
+	if status, ok := attrs[semconv.HTTPStatusCodeKey]; ok {
+		block.Response = &xrayHTTPResponse{
+			Status:        status.AsInt64(),
+			ContentLength: attrs[semconv.HTTPResponseContentLengthKey].AsInt64(),
+		}
+	}
+	return block
+}
+
+// setXrayStatus sets the fault, error and throttle flags: client errors are errors, 429 is also a throttle, and
+// server errors and failed spans without a client error are faults. Recorded exceptions become the cause.
+func setXrayStatus(seg *xraySegment, span sdktrace.ReadOnlySpan, attrs map[attribute.Key]attribute.Value) {
+	status := attrs[semconv.HTTPStatusCodeKey].AsInt64()
+	switch {
+	case status == 429:
+		seg.Error, seg.Throttle = true, true
+	case status >= 400 && status < 500:
+		seg.Error = true
+	case status >= 500:
+		seg.Fault = true
+	case span.Status().Code == codes.Error:
+		seg.Fault = true
+	}
+
+	sid := span.SpanContext().SpanID()
+	base := binary.BigEndian.Uint64(sid[:])
+	for _, event := range span.Events() {
+		if event.Name != semconv.ExceptionEventName {
+			continue
+		}
+		if seg.Cause == nil {
+			seg.Cause = &xrayCause{}
+		}
+		ex := xrayException{ID: fmt.Sprintf("%016x", base+uint64(len(seg.Cause.Exceptions)))}
+		for _, kv := range event.Attributes {
+			switch kv.Key {
+			case semconv.ExceptionTypeKey:
+				ex.Type = kv.Value.AsString()
+			case semconv.ExceptionMessageKey:
+				ex.Message = kv.Value.AsString()
+			}
+		}
+		seg.Cause.Exceptions = append(seg.Cause.Exceptions, ex)
+	}
+	if seg.Cause == nil && span.Status().Code == codes.Error && span.Status().Description != "" {
+		seg.Cause = &xrayCause{Exceptions: []xrayException{{ID: fmt.Sprintf("%016x", base), Message: span.Status().Description}}}
+	}
+}
+
+// setAnnotationsAndMetadata records the attributes listed in annotationKeys as annotations, when their type can be
+// indexed, and the other attributes as metadata of the default namespace.
+func setAnnotationsAndMetadata(seg *xraySegment, kvs []attribute.KeyValue, annotationKeys []string) {
+	annotated := map[string]bool{}
+	for _, key := range annotationKeys {
+		annotated[key] = true
+	}
+	for _, kv := range kvs {
+		if kv.Key == xrayAnnotationsKey {
+			continue
+		}
+		if annotated[string(kv.Key)] {
+			switch kv.Value.Type() {
+			case attribute.STRING, attribute.BOOL, attribute.INT64, attribute.FLOAT64:
+				if seg.Annotations == nil {
+					seg.Annotations = map[string]interface{}{}
+				}
+				seg.Annotations[xrayAnnotationKeyChars.ReplaceAllString(string(kv.Key), "_")] = kv.Value.AsInterface()
+				continue
+			}
+		}
+		if seg.Metadata == nil {
+			seg.Metadata = map[string]map[string]interface{}{"default": {}}
+		}
+		seg.Metadata["default"][string(kv.Key)] = kv.Value.AsInterface()
+	}
+}
@@ -0,0 +1,217 @@
+package collection
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// listenXrayDaemon starts a UDP listener standing in for the X-Ray daemon.
+func listenXrayDaemon(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readSegment reads a datagram from conn and decodes its header and segment document.
+func readSegment(t *testing.T, conn *net.UDPConn) map[string]interface{} {
+	t.Helper()
+	buf := make([]byte, maxXrayDatagram)
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("reading segment: %v", err)
+	}
+	header, body, ok := bytes.Cut(buf[:n], []byte("\n"))
+	if !ok {
+		t.Fatalf("datagram without header: %s", buf[:n])
+	}
+	var h struct {
+		Format  string `json:"format"`
+		Version int    `json:"version"`
+	}
+	if err := json.Unmarshal(header, &h); err != nil {
+		t.Fatalf("decoding header %s: %v", header, err)
+	}
+	if h.Format != "json" || h.Version != 1 {
+		t.Errorf("header = %s, want format json and version 1", header)
+	}
+	var seg map[string]interface{}
+	if err := json.Unmarshal(body, &seg); err != nil {
+		t.Fatalf("decoding segment %s: %v", body, err)
+	}
+	return seg
+}
+
+// field returns the value at path in a decoded document, or nil.
+func field(doc map[string]interface{}, path ...string) interface{} {
+	var v interface{} = doc
+	for _, key := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+func TestXrayDaemonExporter(t *testing.T) {
+	conn := listenXrayDaemon(t)
+	exp, err := NewXrayDaemonExporter(conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp), sdktrace.WithIDGenerator(xray.NewIDGenerator()))
+	defer tp.Shutdown(context.Background())
+	tracer := tp.Tracer("test")
+
+	ctx, server := tracer.Start(context.Background(), "GET /outgoing-http-call",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPMethod("GET"),
+			semconv.HTTPScheme("http"),
+			semconv.HTTPTarget("/outgoing-http-call?x=1"),
+			semconv.NetHostName("localhost"),
+			semconv.NetHostPort(8080),
+			netPeerIPKey.String("10.0.0.7"),
+			semconv.HTTPUserAgent("curl/8.0"),
+			attribute.String("tenant", "acme"),
+			attribute.Int("retries", 2),
+			xrayAnnotationsKey.StringSlice([]string{"tenant", "user.tier"}),
+			attribute.String("user.tier", "gold"),
+		))
+	_, client := tracer.Start(ctx, "S3.GetObject",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.RPCSystemKey.String("aws-api"),
+			semconv.RPCService("S3"),
+			semconv.RPCMethod("GetObject"),
+			attribute.String("aws.region", "us-west-2"),
+			attribute.String("aws.request_id", "req-1"),
+			semconv.HTTPMethod("GET"),
+			semconv.HTTPURL("https://bucket.s3.amazonaws.com/key"),
+			semconv.HTTPStatusCode(404),
+		))
+	client.End()
+	server.SetAttributes(semconv.HTTPStatusCode(500))
+	server.RecordError(errors.New("boom"))
+	server.SetStatus(codes.Error, "boom")
+	server.End()
+
+	sub := readSegment(t, conn)
+	seg := readSegment(t, conn)
+
+	t.Run("segment", func(t *testing.T) {
+		sc := server.SpanContext()
+		if got, want := seg["trace_id"], xrayTraceID(sc.TraceID()); got != want {
+			t.Errorf("trace_id = %v, want %v", got, want)
+		}
+		if got := seg["type"]; got != nil {
+			t.Errorf("type = %v, want a segment", got)
+		}
+		if seg["fault"] != true || seg["error"] != nil {
+			t.Errorf("fault, error = %v, %v, want true, false", seg["fault"], seg["error"])
+		}
+		if got := field(seg, "cause", "exceptions"); got == nil {
+			t.Error("cause has no exception")
+		}
+		if got := field(seg, "http", "request", "method"); got != "GET" {
+			t.Errorf("http.request.method = %v", got)
+		}
+		if got := field(seg, "http", "request", "url"); got != "http://localhost:8080/outgoing-http-call?x=1" {
+			t.Errorf("http.request.url = %v", got)
+		}
+		if got := field(seg, "http", "request", "client_ip"); got != "10.0.0.7" {
+			t.Errorf("http.request.client_ip = %v", got)
+		}
+		if got := field(seg, "http", "response", "status"); got != float64(500) {
+			t.Errorf("http.response.status = %v", got)
+		}
+		if got := field(seg, "aws", "xray", "sdk"); got == nil {
+			t.Error("aws.xray.sdk is not set")
+		}
+		if got := field(seg, "annotations", "tenant"); got != "acme" {
+			t.Errorf("annotations.tenant = %v", got)
+		}
+		if got := field(seg, "annotations", "user_tier"); got != "gold" {
+			t.Errorf("annotations.user_tier = %v", got)
+		}
+		if got := field(seg, "metadata", "default", "retries"); got != float64(2) {
+			t.Errorf("metadata.default.retries = %v", got)
+		}
+		if got := field(seg, "metadata", "default", string(xrayAnnotationsKey)); got != nil {
+			t.Errorf("the annotation list is recorded as metadata: %v", got)
+		}
+	})
+
+	t.Run("subsegment", func(t *testing.T) {
+		if got := sub["type"]; got != "subsegment" {
+			t.Errorf("type = %v, want subsegment", got)
+		}
+		if got, want := sub["parent_id"], server.SpanContext().SpanID().String(); got != want {
+			t.Errorf("parent_id = %v, want %v", got, want)
+		}
+		if sub["name"] != "S3" || sub["namespace"] != "aws" {
+			t.Errorf("name, namespace = %v, %v, want S3, aws", sub["name"], sub["namespace"])
+		}
+		if sub["error"] != true || sub["fault"] != nil || sub["throttle"] != nil {
+			t.Errorf("error, fault, throttle = %v, %v, %v, want true, false, false", sub["error"], sub["fault"], sub["throttle"])
+		}
+		if got := field(sub, "aws", "operation"); got != "GetObject" {
+			t.Errorf("aws.operation = %v", got)
+		}
+		if got := field(sub, "aws", "region"); got != "us-west-2" {
+			t.Errorf("aws.region = %v", got)
+		}
+		if got := field(sub, "http", "request", "url"); got != "https://bucket.s3.amazonaws.com/key" {
+			t.Errorf("http.request.url = %v", got)
+		}
+		if got := field(sub, "http", "response", "status"); got != float64(404) {
+			t.Errorf("http.response.status = %v", got)
+		}
+	})
+}
+
+func TestXrayName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"allowed characters", "GET /db-call", "GET /db-call"},
+		{"removed characters", "a<b>c?", "abc"},
+		{"truncated", strings.Repeat("a", 250), strings.Repeat("a", 200)},
+		{"truncated on a rune boundary", strings.Repeat("é", 250), strings.Repeat("é", 200)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := xrayName(tt.in)
+			if got != tt.want {
+				t.Errorf("xrayName() = %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("xrayName() = %q is not valid UTF-8", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/aws-otel-commnunity/sample-apps/go-sample-app/collection"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/spf13/pflag"
+	lambdadetector "go.opentelemetry.io/contrib/detectors/aws/lambda"
+)
+
+func lambdaFlags(fs *pflag.FlagSet) {
+	fs.String("event", "", "Feed the API Gateway or ALB event JSON in this file through the handler and print the response, instead of starting the Lambda runtime")
+}
+
+// serveLambda serves the sample app endpoints from AWS Lambda. With --event, a single event fixture is fed through the
+// handler so the Lambda entrypoint can be tested locally.
+func serveLambda(ctx context.Context, cfg *collection.Config, fs *pflag.FlagSet) error {
+	app, err := collection.New(ctx,
+		collection.WithConfig(cfg),
+		collection.WithResourceDetectors(lambdadetector.NewResourceDetector()),
+	)
+	if err != nil {
+		return err
+	}
+	defer app.Shutdown(ctx)
+
+	if err := app.Start(ctx); err != nil {
+		return err
+	}
+	handler := app.LambdaHandler()
+
+	eventFile, _ := fs.GetString("event")
+	if eventFile == "" {
+		lambda.StartWithOptions(handler, lambda.WithContext(ctx))
+		return nil
+	}
+
+	event, err := os.ReadFile(eventFile)
+	if err != nil {
+		return err
+	}
+	resp, err := handler(ctx, event)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
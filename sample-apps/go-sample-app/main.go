@@ -2,79 +2,164 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
-	"math/rand"
 	"net"
 	"net/http"
-	"time"
+	"os"
+	"strings"
 
 	"github.com/aws-otel-commnunity/sample-apps/go-sample-app/collection"
-	"github.com/gorilla/mux"
-	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
-	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
-	"go.opentelemetry.io/otel"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
 )
 
-// This sample application is in conformance with the ADOT SampleApp requirements spec.
-func main() {
-	ctx := context.Background()
+// command is a subcommand of the sample app CLI.
+type command struct {
+	name  string
+	usage string
+	flags func(fs *pflag.FlagSet)
+	run   func(ctx context.Context, cfg *collection.Config, fs *pflag.FlagSet) error
+}
 
-	// The seed for 'random' values used in this applicaiton
-	rand.Seed(time.Now().UnixNano())
+var commands = []command{
+	{"serve", "Start the sample app web server (default)", serveFlags, serve},
+	{"generate-traffic", "Send requests to the endpoints of a running sample app", trafficFlags, generateTraffic},
+	{"validate", "Validate the resolved configuration and exit", nil, validate},
+	{"print-config", "Print the resolved configuration and exit", nil, printConfig},
+	{"lambda", "Serve the endpoints from AWS Lambda (default on Lambda)", lambdaFlags, serveLambda},
+}
 
-	// Client starts
-	shutdown, err := collection.StartClient(ctx)
-	if err != nil {
-		log.Fatal(err)
+// This sample application is in conformance with the ADOT SampleApp requirements spec.
+func main() {
+	args := os.Args[1:]
+	if len(args) > 0 && (args[0] == "help" || args[0] == "-h" || args[0] == "--help") {
+		printUsage()
+		return
+	}
+	name := "serve"
+	if _, onLambda := os.LookupEnv("AWS_LAMBDA_RUNTIME_API"); onLambda {
+		name = "lambda"
+	}
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
-	defer shutdown(ctx)
 
-	// (Metric related) Creates and configures random based metrics based on a configuration file (config.yaml).
-	mp := otel.GetMeterProvider()
-	cfg := collection.GetConfiguration()
+	for _, cmd := range commands {
+		if cmd.name == name {
+			os.Exit(runCommand(cmd, args))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	printUsage()
+	os.Exit(2)
+}
 
-	// (Metric related) Starts request based metric and registers necessary callbacks
-	rmc := collection.NewRandomMetricCollector(mp)
-	rmc.RegisterMetricsClient(ctx, *cfg)
-	rqmc := collection.NewRequestBasedMetricCollector(ctx, *cfg, mp)
-	rqmc.StartTotalRequestCallback()
+// runCommand parses the flags of cmd, resolves the configuration and runs cmd. It returns the exit code.
+func runCommand(cmd command, args []string) int {
+	fs := pflag.NewFlagSet(cmd.name, pflag.ContinueOnError)
+	fs.SortFlags = false
+	configFile := fs.String("config", collection.ConfigFile(), "Configuration file (YAML), also set by SAMPLE_APP_CONF")
+	if cmd.flags != nil {
+		cmd.flags(fs)
+	}
+	collection.RegisterFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s\n\nUsage:\n  go-sample-app %s [flags]\n\nFlags:\n%s", cmd.usage, cmd.name, fs.FlagUsages())
+		fmt.Fprintf(os.Stderr, "\nPrecedence: defaults < configuration file < environment variables (%s_<KEY>) < flags\n", collection.EnvPrefix)
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
 
-	s3Client, err := collection.NewS3Client()
+	cfg, err := collection.LoadConfiguration(*configFile, fs)
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
+		return 1
 	}
-	// Creates a router, client and web server with several endpoints
-	r := mux.NewRouter()
-	client := http.Client{
-		Transport: otelhttp.NewTransport(http.DefaultTransport),
+	if err := cmd.run(context.Background(), cfg, fs); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
 	}
+	return 0
+}
 
-	r.Use(otelmux.Middleware("Go-Sampleapp-Server"))
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Go sample app for the AWS Distro for OpenTelemetry\n\nUsage:\n  go-sample-app [command] [flags]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-18s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nUse \"go-sample-app [command] --help\" for the flags of a command.\n")
+}
+
+func serveFlags(fs *pflag.FlagSet) {
+	fs.Bool("dry-run", false, "Print the resolved configuration instead of starting the server")
+}
 
-	// Three endpoints
-	r.HandleFunc("/aws-sdk-call", func(w http.ResponseWriter, r *http.Request) {
-		collection.AwsSdkCall(w, r, &rqmc, s3Client)
-	})
+// serve starts the sample app web server.
+func serve(ctx context.Context, cfg *collection.Config, fs *pflag.FlagSet) error {
+	if dryRun, _ := fs.GetBool("dry-run"); dryRun {
+		return printConfig(ctx, cfg, fs)
+	}
 
-	r.HandleFunc("/outgoing-http-call", func(w http.ResponseWriter, r *http.Request) {
-		collection.OutgoingHttpCall(w, r, client, &rqmc)
-	})
+	// App starts its own trace and metric providers
+	app, err := collection.New(ctx, collection.WithConfig(cfg))
+	if err != nil {
+		return err
+	}
+	defer app.Shutdown(ctx)
 
-	r.HandleFunc("/outgoing-sampleapp", func(w http.ResponseWriter, r *http.Request) {
-		collection.OutgoingSampleApp(w, r, client, &rqmc)
-	})
+	// (Metric related) Starts random based metrics and registers the request based metric callbacks
+	if err := app.Start(ctx); err != nil {
+		return err
+	}
 
-	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	})
-	// Root endpoint
-	http.Handle("/", r)
+	// Serves the same operations over gRPC when a gRPC port is configured
+	if addr := app.GRPCAddr(); addr != "" {
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		grpcSrv := app.GRPCServer()
+		defer grpcSrv.GracefulStop()
+		go func() {
+			if err := grpcSrv.Serve(lis); err != nil {
+				fmt.Println(err)
+			}
+		}()
+		fmt.Println("Listening for gRPC on port:", addr)
+	}
 
 	srv := &http.Server{
-		Addr: net.JoinHostPort(cfg.Host, cfg.Port),
+		Addr:    app.Addr(),
+		Handler: app.Handler(),
 	}
 	fmt.Println("Listening on port:", srv.Addr)
-	log.Fatal(srv.ListenAndServe())
+	return srv.ListenAndServe()
+}
+
+// validate reports every problem of the configuration.
+func validate(ctx context.Context, cfg *collection.Config, fs *pflag.FlagSet) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	fmt.Println("configuration is valid")
+	return nil
+}
 
+// printConfig prints the resolved configuration as YAML. The debug token is redacted.
+func printConfig(ctx context.Context, cfg *collection.Config, fs *pflag.FlagSet) error {
+	resolved := *cfg
+	if resolved.DebugToken != "" {
+		resolved.DebugToken = "<redacted>"
+	}
+	out, err := yaml.Marshal(resolved)
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(out))
+	return nil
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws-otel-commnunity/sample-apps/go-sample-app/collection"
+	"github.com/spf13/pflag"
+)
+
+var defaultTrafficEndpoints = []string{"/outgoing-http-call", "/aws-sdk-call", "/outgoing-sampleapp"}
+
+func trafficFlags(fs *pflag.FlagSet) {
+	fs.String("target", "", "Base URL of the sample app to send requests to, defaults to the configured Host and Port")
+	fs.StringSlice("endpoints", defaultTrafficEndpoints, "Endpoints to call, in round robin order")
+	fs.Int("requests", 100, "Total number of requests to send, 0 to send requests until --duration elapses")
+	fs.Int("concurrency", 1, "Number of concurrent workers")
+	fs.Float64("rate", 0, "Maximum number of requests per second across all workers, 0 for no limit")
+	fs.Duration("duration", 0, "Stop sending requests after this duration, 0 for no limit")
+}
+
+// trafficStats aggregates the outcome of the requests sent to one endpoint.
+type trafficStats struct {
+	requests int
+	errors   int
+	statuses map[int]int
+	total    time.Duration
+}
+
+// generateTraffic sends requests to the endpoints of a running sample app and prints a summary per endpoint.
+func generateTraffic(ctx context.Context, cfg *collection.Config, fs *pflag.FlagSet) error {
+	target, _ := fs.GetString("target")
+	endpoints, _ := fs.GetStringSlice("endpoints")
+	requests, _ := fs.GetInt("requests")
+	concurrency, _ := fs.GetInt("concurrency")
+	rate, _ := fs.GetFloat64("rate")
+	duration, _ := fs.GetDuration("duration")
+
+	if len(endpoints) == 0 {
+		return fmt.Errorf("at least one endpoint is required")
+	}
+	if requests <= 0 && duration <= 0 {
+		return fmt.Errorf("either --requests or --duration must be set")
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if target == "" {
+		host := cfg.Host
+		if host == "0.0.0.0" || host == "" {
+			host = "localhost"
+		}
+		target = "http://" + net.JoinHostPort(host, cfg.Port)
+	}
+	if duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+
+	// jobs hands out endpoints in round robin order, optionally throttled to rate requests per second
+	jobs := make(chan string)
+	go func() {
+		defer close(jobs)
+		var ticker *time.Ticker
+		if rate > 0 {
+			ticker = time.NewTicker(time.Duration(float64(time.Second) / rate))
+			defer ticker.Stop()
+		}
+		for i := 0; requests <= 0 || i < requests; i++ {
+			if ticker != nil {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- endpoints[i%len(endpoints)]:
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	stats := map[string]*trafficStats{}
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for endpoint := range jobs {
+				start := time.Now()
+				status, err := sendRequest(ctx, client, target+endpoint)
+				elapsed := time.Since(start)
+
+				mu.Lock()
+				st, ok := stats[endpoint]
+				if !ok {
+					st = &trafficStats{statuses: map[int]int{}}
+					stats[endpoint] = st
+				}
+				st.requests++
+				st.total += elapsed
+				if err != nil {
+					st.errors++
+				} else {
+					st.statuses[status]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	printTrafficStats(target, stats)
+	return nil
+}
+
+// sendRequest sends a GET request to url and returns the status code of the response.
+func sendRequest(ctx context.Context, client *http.Client, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	return res.StatusCode, nil
+}
+
+func printTrafficStats(target string, stats map[string]*trafficStats) {
+	endpoints := make([]string, 0, len(stats))
+	for endpoint := range stats {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+
+	fmt.Println("Traffic sent to", target)
+	for _, endpoint := range endpoints {
+		st := stats[endpoint]
+		fmt.Printf("  %-22s requests=%d errors=%d avgLatency=%s statuses=%v\n",
+			endpoint, st.requests, st.errors, (st.total / time.Duration(st.requests)).Round(time.Millisecond), st.statuses)
+	}
+}